@@ -4,6 +4,7 @@ import "fmt"
 
 // Assign is an assignment statement.
 type Assign struct {
+	Span
 	Left  Node
 	Right Node
 }
@@ -1,8 +1,29 @@
 // Package ast contains data structures for the AST.
 package ast
 
+import "github.com/pseidemann/tik/source"
+
 // Node is an AST node.
 type Node interface {
 	String() string
 	Children() []Node
+	Pos() source.Pos
+	End() source.Pos
+}
+
+// Span is the source range a node was parsed from.
+// It is embedded in every node.
+type Span struct {
+	From source.Pos
+	To   source.Pos
+}
+
+// Pos returns the position of the first character of the node.
+func (s Span) Pos() source.Pos {
+	return s.From
+}
+
+// End returns the position immediately after the node.
+func (s Span) End() source.Pos {
+	return s.To
 }
@@ -4,6 +4,7 @@ import "fmt"
 
 // Block is a chunk of statements.
 type Block struct {
+	Span
 	Name  string
 	Stmts []Node
 }
@@ -0,0 +1,18 @@
+package ast
+
+import "strconv"
+
+// Bool is a truth value.
+type Bool struct {
+	Span
+	Value bool
+}
+
+func (b *Bool) String() string {
+	return strconv.FormatBool(b.Value)
+}
+
+// Children returns the node's children.
+func (b *Bool) Children() []Node {
+	return nil
+}
@@ -0,0 +1,15 @@
+package ast
+
+// Break exits the innermost loop.
+type Break struct {
+	Span
+}
+
+func (b *Break) String() string {
+	return "(break)"
+}
+
+// Children returns the node's children.
+func (b *Break) Children() []Node {
+	return nil
+}
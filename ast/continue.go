@@ -0,0 +1,15 @@
+package ast
+
+// Continue skips to the next iteration of the innermost loop.
+type Continue struct {
+	Span
+}
+
+func (c *Continue) String() string {
+	return "(continue)"
+}
+
+// Children returns the node's children.
+func (c *Continue) Children() []Node {
+	return nil
+}
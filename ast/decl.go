@@ -0,0 +1,25 @@
+package ast
+
+import "fmt"
+
+// Decl is the declaration of a variable in the current scope, like let x = 1.
+// Constants, like const x = 1, can't be assigned to after their declaration.
+type Decl struct {
+	Span
+	Name  *Ident
+	Value Node
+	Const bool
+}
+
+func (d *Decl) String() string {
+	kw := "let"
+	if d.Const {
+		kw = "const"
+	}
+	return fmt.Sprintf("(%s %v = %v)", kw, d.Name, d.Value)
+}
+
+// Children returns the node's children.
+func (d *Decl) Children() []Node {
+	return nil
+}
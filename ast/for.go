@@ -0,0 +1,25 @@
+package ast
+
+// For executes a block repeatedly as long as a condition is true.
+type For struct {
+	Span
+	Init Node // optional statement executed before the first iteration
+	Cond Node // optional condition, the loop is endless without it
+	Post Node // optional statement executed after each iteration
+	Body *Block
+}
+
+func (f *For) String() string {
+	return "(for)"
+}
+
+// Children returns the node's children.
+func (f *For) Children() []Node {
+	var children []Node
+	for _, n := range []Node{f.Init, f.Cond, f.Post} {
+		if n != nil {
+			children = append(children, n)
+		}
+	}
+	return append(children, f.Body)
+}
@@ -0,0 +1,22 @@
+package ast
+
+import "fmt"
+
+// ForIn executes a block for each element of a list, string or map.
+// With one variable, it is set to the element of a list or string and the key of a map.
+// With two variables, they are set to the index and element or to the key and value.
+type ForIn struct {
+	Span
+	Vars []*Ident
+	Iter Node
+	Body *Block
+}
+
+func (f *ForIn) String() string {
+	return fmt.Sprintf("(for %v in)", f.Vars)
+}
+
+// Children returns the node's children.
+func (f *ForIn) Children() []Node {
+	return []Node{f.Iter, f.Body}
+}
@@ -3,13 +3,18 @@ package ast
 import "fmt"
 
 // FuncCall is the calling of a function.
+// The function is usually an Ident, but can be any expression, like in f(1)(2).
 type FuncCall struct {
-	Name string
+	Span
+	Func Node
 	Args []Node
 }
 
 func (f *FuncCall) String() string {
-	return fmt.Sprintf("(funccall=%v %v)", f.Name, f.Args)
+	if ident, ok := f.Func.(*Ident); ok {
+		return fmt.Sprintf("(funccall=%v %v)", ident.Name, f.Args)
+	}
+	return fmt.Sprintf("(funccall=%v %v)", f.Func, f.Args)
 }
 
 // Children returns the node's children.
@@ -4,6 +4,7 @@ import "fmt"
 
 // FuncDef is the declaration of a function.
 type FuncDef struct {
+	Span
 	Name   string
 	Params []*Param
 	Body   *Block
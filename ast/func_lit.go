@@ -0,0 +1,19 @@
+package ast
+
+import "fmt"
+
+// FuncLit is an anonymous function like func(x) { return x }.
+type FuncLit struct {
+	Span
+	Params []*Param
+	Body   *Block
+}
+
+func (f *FuncLit) String() string {
+	return fmt.Sprintf("(funclit %v)", f.Params)
+}
+
+// Children returns the node's children.
+func (f *FuncLit) Children() []Node {
+	return []Node{f.Body}
+}
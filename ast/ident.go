@@ -4,6 +4,7 @@ import "fmt"
 
 // Ident is a variable.
 type Ident struct {
+	Span
 	Name string
 }
 
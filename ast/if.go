@@ -0,0 +1,21 @@
+package ast
+
+// If executes a block depending on a condition.
+type If struct {
+	Span
+	Cond Node
+	Then *Block
+	Else Node // nil, *Block or *If
+}
+
+func (i *If) String() string {
+	return "(if)"
+}
+
+// Children returns the node's children.
+func (i *If) Children() []Node {
+	if i.Else == nil {
+		return []Node{i.Cond, i.Then}
+	}
+	return []Node{i.Cond, i.Then, i.Else}
+}
@@ -0,0 +1,19 @@
+package ast
+
+import "fmt"
+
+// Index is the access of an element like xs[i].
+type Index struct {
+	Span
+	Left  Node
+	Index Node
+}
+
+func (i *Index) String() string {
+	return fmt.Sprintf("(index %v[%v])", i.Left, i.Index)
+}
+
+// Children returns the node's children.
+func (i *Index) Children() []Node {
+	return []Node{i.Left, i.Index}
+}
@@ -0,0 +1,19 @@
+package ast
+
+import "fmt"
+
+// Interpolation is a string literal with embedded expressions like "a ${b} c".
+// Its parts are the literal pieces as String nodes and the embedded expressions, in order.
+type Interpolation struct {
+	Span
+	Parts []Node
+}
+
+func (i *Interpolation) String() string {
+	return fmt.Sprintf("(interpolation %v)", i.Parts)
+}
+
+// Children returns the node's children.
+func (i *Interpolation) Children() []Node {
+	return i.Parts
+}
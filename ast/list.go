@@ -0,0 +1,18 @@
+package ast
+
+import "fmt"
+
+// List is a list literal like [1, 2, 3].
+type List struct {
+	Span
+	Elems []Node
+}
+
+func (l *List) String() string {
+	return fmt.Sprintf("(list %v)", l.Elems)
+}
+
+// Children returns the node's children.
+func (l *List) Children() []Node {
+	return l.Elems
+}
@@ -0,0 +1,24 @@
+package ast
+
+import "fmt"
+
+// Map is a map literal like {"a": 1, "b": 2}.
+// Keys and Values have the same length, the i-th key belongs to the i-th value.
+type Map struct {
+	Span
+	Keys   []Node
+	Values []Node
+}
+
+func (m *Map) String() string {
+	return fmt.Sprintf("(map %v %v)", m.Keys, m.Values)
+}
+
+// Children returns the node's children, alternating between keys and values.
+func (m *Map) Children() []Node {
+	children := make([]Node, 0, 2*len(m.Keys))
+	for i := range m.Keys {
+		children = append(children, m.Keys[i], m.Values[i])
+	}
+	return children
+}
@@ -2,6 +2,7 @@ package ast
 
 // Number is a mathematical object.
 type Number struct {
+	Span
 	Num string
 }
 
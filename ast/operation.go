@@ -11,6 +11,17 @@ const (
 	OpSub
 	OpMul
 	OpDiv
+	OpMod
+	OpEq
+	OpNe
+	OpLt
+	OpLe
+	OpGt
+	OpGe
+	OpAnd
+	OpOr
+	OpNot
+	OpNeg
 )
 
 var types = [...]string{
@@ -18,10 +29,26 @@ var types = [...]string{
 	"-",
 	"*",
 	"/",
+	"%",
+	"==",
+	"!=",
+	"<",
+	"<=",
+	">",
+	">=",
+	"&&",
+	"||",
+	"!",
+	"-",
+}
+
+func (o OpType) String() string {
+	return types[o]
 }
 
-// Operation is a arithmetic operation.
+// Operation is a binary operation.
 type Operation struct {
+	Span
 	OpType OpType
 	Left   Node
 	Right  Node
@@ -4,6 +4,7 @@ import "fmt"
 
 // Param is an argument in a function declaration.
 type Param struct {
+	Span
 	Name string
 }
 
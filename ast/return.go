@@ -4,6 +4,7 @@ import "fmt"
 
 // Return exits a function with an optional value.
 type Return struct {
+	Span
 	Value Node
 }
 
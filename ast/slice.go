@@ -0,0 +1,28 @@
+package ast
+
+import "fmt"
+
+// Slice is the access of a range of elements like xs[low:high].
+// Low and High are nil if they are omitted.
+type Slice struct {
+	Span
+	Left Node
+	Low  Node
+	High Node
+}
+
+func (s *Slice) String() string {
+	return fmt.Sprintf("(slice %v[%v:%v])", s.Left, s.Low, s.High)
+}
+
+// Children returns the node's children.
+func (s *Slice) Children() []Node {
+	children := []Node{s.Left}
+	if s.Low != nil {
+		children = append(children, s.Low)
+	}
+	if s.High != nil {
+		children = append(children, s.High)
+	}
+	return children
+}
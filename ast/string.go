@@ -4,6 +4,7 @@ import "fmt"
 
 // String is a sequence of characters.
 type String struct {
+	Span
 	Str string
 }
 
@@ -0,0 +1,19 @@
+package ast
+
+import "fmt"
+
+// UnaryOperation is an operation with a single operand, like a negation.
+type UnaryOperation struct {
+	Span
+	OpType  OpType
+	Operand Node
+}
+
+func (u *UnaryOperation) String() string {
+	return fmt.Sprintf("(op `%v` operand:%v)", u.OpType, u.Operand)
+}
+
+// Children returns the node's children.
+func (u *UnaryOperation) Children() []Node {
+	return []Node{u.Operand}
+}
@@ -2,22 +2,97 @@
 package inspect
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"reflect"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/pseidemann/tik/ast"
 )
 
 // PrintAST prints AST nodes.
 func PrintAST(root ast.Node) {
-	print(root, 0)
+	FprintAST(os.Stdout, root)
 }
 
-func print(n ast.Node, depth int) {
+// FprintAST prints AST nodes to w.
+func FprintAST(w io.Writer, root ast.Node) {
+	print(w, root, 0)
+}
+
+func print(w io.Writer, n ast.Node, depth int) {
 	indent := strings.Repeat("    ", depth)
-	fmt.Printf("%s|__ %s\n", indent, n)
+	from, to := n.Pos(), n.End()
+	fmt.Fprintf(w, "%s|__ %s <%d:%d-%d:%d>\n", indent, n, from.Line, from.Column, to.Line, to.Column)
 	depth++
 	for _, child := range n.Children() {
-		print(child, depth)
+		print(w, child, depth)
+	}
+}
+
+// WriteJSON writes the AST as JSON to w.
+// Every node is an object with the node type in "node", its span in "pos" and "end"
+// and the fields of the node with lower-cased names.
+func WriteJSON(w io.Writer, root ast.Node) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(toJSON(reflect.ValueOf(root)))
+}
+
+var (
+	nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+	spanType = reflect.TypeOf(ast.Span{})
+)
+
+// toJSON converts a value of the AST into data which can be encoded by encoding/json.
+func toJSON(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
 	}
+
+	if v.Type().Implements(nodeType) && v.Kind() != reflect.Interface {
+		n := v.Interface().(ast.Node)
+		obj := map[string]interface{}{
+			"node": reflect.Indirect(v).Type().Name(),
+			"pos":  n.Pos(),
+			"end":  n.End(),
+		}
+		s := reflect.Indirect(v)
+		for i := 0; i < s.NumField(); i++ {
+			field := s.Type().Field(i)
+			if field.Type == spanType || field.PkgPath != "" {
+				continue
+			}
+			obj[lowerFirst(field.Name)] = toJSON(s.Field(i))
+		}
+		return obj
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		return toJSON(v.Elem())
+	case reflect.Slice:
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = toJSON(v.Index(i))
+		}
+		return list
+	}
+
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return v.Interface()
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
 }
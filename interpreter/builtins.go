@@ -0,0 +1,171 @@
+package interpreter
+
+import (
+	"io"
+	"strings"
+
+	"github.com/pseidemann/tik/ast"
+)
+
+// builtin is a function implemented in Go.
+// The arguments are evaluated already and nil for expressions without value.
+type builtin func(in *Interpreter, call *ast.FuncCall, args []*variable) *variable
+
+// builtinFuncs contains the function values of all builtins by name.
+var builtinFuncs map[string]*variable
+
+// init sets up the builtins, because a map literal would lead to an
+// initialization loop as soon as a builtin calls back into the interpreter.
+func init() {
+	builtins := map[string]builtin{
+		"arg":      builtinArg,
+		"append":   builtinAppend,
+		"argc":     builtinArgc,
+		"contains": builtinContains,
+		"delete":   builtinDelete,
+		"eprint":   builtinEprint,
+		"flush":    builtinFlush,
+		"has":      builtinHas,
+		"index":    builtinIndex,
+		"input":    builtinInput,
+		"join":     builtinJoin,
+		"keys":     builtinKeys,
+		"len":      builtinLen,
+		"lower":    builtinLower,
+		"pop":      builtinPop,
+		"print":    builtinPrint,
+		"readline": builtinReadline,
+		"repeat":   builtinRepeat,
+		"replace":  builtinReplace,
+		"split":    builtinSplit,
+		"substr":   builtinSubstr,
+		"trim":     builtinTrim,
+		"upper":    builtinUpper,
+	}
+	builtinFuncs = make(map[string]*variable, len(builtins))
+	for name, b := range builtins {
+		builtinFuncs[name] = &variable{varType: TypeFunc, funcVal: &function{name: name, builtin: b}}
+	}
+}
+
+// checkArgs makes sure that the builtin is called with arguments of the given types.
+func (in *Interpreter) checkArgs(call *ast.FuncCall, args []*variable, types ...Type) {
+	if len(args) != len(types) {
+		panic(in.errorf(call, "function %q expects %d args, got %d", callName(call), len(types), len(args)))
+	}
+	for i, arg := range args {
+		if arg == nil {
+			panic(in.errorf(call.Args[i], "argument %d of %q has no value", i+1, callName(call)))
+		}
+		if types[i] != TypeAny && arg.varType != types[i] {
+			panic(in.errorf(call.Args[i], "argument %d of %q must be %v, got %v", i+1, callName(call), types[i], arg.varType))
+		}
+	}
+}
+
+func builtinPrint(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	line := in.formatLine(call, args)
+	in.addOutput(call, len(line))
+	in.stdout.WriteString(line)
+	return nil
+}
+
+// builtinEprint prints like print, but to stderr.
+func builtinEprint(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	line := in.formatLine(call, args)
+	in.addOutput(call, len(line))
+	// keep the order of the output if both are written to the same terminal
+	in.flush(call)
+	io.WriteString(in.stderr, line)
+	return nil
+}
+
+// formatLine joins the values with spaces and adds a newline, like print shows them.
+// It stops as soon as the line doesn't fit into the remaining output budget.
+func (in *Interpreter) formatLine(call *ast.FuncCall, args []*variable) string {
+	f := in.formatter(call, func(length int) {
+		in.checkOutput(call, length)
+	})
+	lastIdx := len(args) - 1
+	for i, vari := range args {
+		if vari == nil {
+			// function without return value
+			continue
+		}
+		f.format(vari, false)
+		if i < lastIdx {
+			f.b.WriteRune(' ')
+		}
+	}
+	f.b.WriteRune('\n')
+	return f.b.String()
+}
+
+func builtinFlush(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args)
+	in.flush(call)
+	return nil
+}
+
+// flush writes the buffered output to stdout.
+func (in *Interpreter) flush(n ast.Node) {
+	if err := in.stdout.Flush(); err != nil {
+		rtErr := in.errorf(n, "cannot write output: %v", err)
+		rtErr.Err = err
+		panic(rtErr)
+	}
+}
+
+// builtinInput prints the optional prompt and returns the next line of stdin without the newline.
+// Other than readline, it fails at the end of the input.
+func builtinInput(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	if len(args) > 1 {
+		panic(in.errorf(call, "function %q expects at most 1 args, got %d", callName(call), len(args)))
+	}
+	if len(args) == 1 {
+		in.checkArgs(call, args, TypeString)
+		in.addOutput(call, len(args[0].strVal))
+		in.stdout.WriteString(args[0].strVal)
+	}
+	in.flush(call)
+	line := in.readLine(call)
+	if line == "" {
+		panic(in.errorf(call, "unexpected end of input"))
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return newString(strings.TrimSuffix(line, "\r"))
+}
+
+// builtinReadline returns the next line of stdin including the newline.
+// At the end of the input, it returns an empty string.
+func builtinReadline(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args)
+	in.flush(call)
+	return newString(in.readLine(call))
+}
+
+// readLine reads the next line of stdin including the newline, if any.
+func (in *Interpreter) readLine(n ast.Node) string {
+	line, err := in.stdin.ReadString('\n')
+	if err != nil && err != io.EOF {
+		rtErr := in.errorf(n, "cannot read input: %v", err)
+		rtErr.Err = err
+		panic(rtErr)
+	}
+	in.checkStringLen(n, len(line))
+	return line
+}
+
+func builtinArgc(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args)
+	return &variable{varType: TypeInt, intVal: len(in.args)}
+}
+
+func builtinArg(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeInt)
+	i := args[0].intVal
+	if i < 0 || i >= len(in.args) {
+		panic(in.errorf(call.Args[0], "argument index %d out of range, argc() is %d", i, len(in.args)))
+	}
+	return &variable{varType: TypeString, strVal: in.args[i]}
+}
@@ -0,0 +1,95 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/pseidemann/tik/ast"
+	"github.com/pseidemann/tik/lexer"
+)
+
+// Func is a Go function which can be called from tik programs after adding it with Register.
+// A returned error aborts the program with a RuntimeError at the call, which wraps the error.
+// Returning the zero Value means that the function has no result.
+type Func func(args []Value) (Value, error)
+
+// Register makes fn callable from tik programs under the given name. It panics if the name
+// is no valid identifier, like httpStatus or if, because programs couldn't call it.
+// Before fn is called, the interpreter checks that it gets exactly one argument
+// for each of params, of the given type or of any type for TypeAny.
+//
+// Registered functions take precedence over the builtins of the same name,
+// while variables declared by the program take precedence over both.
+func (in *Interpreter) Register(name string, fn Func, params ...Type) {
+	if !lexer.IsIdentifier(name) {
+		panic(fmt.Sprintf("interpreter: invalid function name %q", name))
+	}
+	params = append([]Type(nil), params...)
+	b := func(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+		in.checkArgs(call, args, params...)
+		values := make([]Value, len(args))
+		for i, arg := range args {
+			values[i] = Value{arg}
+		}
+		result, err := fn(values)
+		if err != nil {
+			rtErr := in.errorf(call, "%v", err)
+			rtErr.Err = err
+			panic(rtErr)
+		}
+		return result.v
+	}
+	in.natives[name] = &variable{varType: TypeFunc, funcVal: &function{name: name, builtin: b}}
+}
+
+// SetGlobal sets a global variable of the program, replacing a variable of the same name.
+// The variable is declared for the program, so it must not declare it again with let.
+// SetGlobal can be called before the execution or from a registered function.
+func (in *Interpreter) SetGlobal(name string, v Value) {
+	if !v.IsValid() {
+		panic("interpreter: SetGlobal with zero Value")
+	}
+	in.globals.vars[name] = &binding{value: v.v}
+}
+
+// Global returns the value of a global variable, e.g. a result after the execution.
+// Functions defined by the program are global variables, too.
+// It reports false if the program doesn't declare the variable.
+func (in *Interpreter) Global(name string) (Value, bool) {
+	b, ok := in.globals.vars[name]
+	if !ok {
+		return Value{}, false
+	}
+	return Value{b.value}, true
+}
+
+// Call calls the function which is stored in the global variable of the given name, typically
+// a function defined by the program after it was executed. It returns the result of the
+// function, which is the zero Value if it has none. Errors during the call are returned as
+// *RuntimeError, but the call itself has no position in the source code.
+func (in *Interpreter) Call(name string, args ...Value) (result Value, err error) {
+	fn, ok := in.Global(name)
+	if !ok {
+		return Value{}, fmt.Errorf("undefined function %q", name)
+	}
+	if fn.Type() != TypeFunc {
+		return Value{}, fmt.Errorf("cannot call %v", fn.Type())
+	}
+	// the arguments have no nodes, so placeholders give error messages a node to refer to
+	call := &ast.FuncCall{Func: &ast.Ident{Name: name}, Args: make([]ast.Node, len(args))}
+	vars := make([]*variable, len(args))
+	for i, arg := range args {
+		if !arg.IsValid() {
+			return Value{}, fmt.Errorf("argument %d of %q has no value", i+1, name)
+		}
+		call.Args[i] = &ast.Ident{}
+		vars[i] = arg.v
+	}
+	f := fn.v.funcVal
+	err = in.run(func() {
+		if f.builtin == nil {
+			in.checkArity(call, f, len(vars))
+		}
+		result = Value{in.callFunc(call, f, vars)}
+	})
+	return result, err
+}
@@ -0,0 +1,223 @@
+package interpreter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	var out bytes.Buffer
+	in := New(&out)
+	in.Register("status", func(args []Value) (Value, error) {
+		if args[0].Int() == 404 {
+			return String("not found"), nil
+		}
+		return String("ok"), nil
+	}, TypeInt)
+	in.Register("scale", func(args []Value) (Value, error) {
+		var scaled []float64
+		for _, v := range args[0].List() {
+			scaled = append(scaled, v.Float()*args[1].Float())
+		}
+		return ValueOf(scaled)
+	}, TypeList, TypeAny)
+	in.Register("config", func(args []Value) (Value, error) {
+		return ValueOf(map[string]interface{}{"retries": 3, "hosts": []string{"a", "b"}})
+	})
+	in.Register("nothing", func(args []Value) (Value, error) {
+		return Value{}, nil
+	})
+	// registered functions can replace builtins
+	in.Register("upper", func(args []Value) (Value, error) {
+		return String("UPPER"), nil
+	}, TypeString)
+
+	src := "print(status(404), status(200))\nprint(scale([1, 2.5], 2))\nprint(config())\nprint(nothing(), upper(\"x\"), status)\n"
+	err := in.Execute(parse(t, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "not found ok\n[2.0, 5.0]\n{\"hosts\": [\"a\", \"b\"], \"retries\": 3}\nUPPER <builtin status>\n"
+	if out.String() != expected {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestRegisterInvalidName(t *testing.T) {
+	for _, name := range []string{"", "httpStatus", "http_status", "if", "for"} {
+		func() {
+			defer func() {
+				if r := recover(); r != fmt.Sprintf("interpreter: invalid function name %q", name) {
+					t.Errorf("%q: unexpected panic %v", name, r)
+				}
+			}()
+			New(&bytes.Buffer{}).Register(name, func(args []Value) (Value, error) {
+				return Value{}, nil
+			})
+		}()
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	errFailed := errors.New("request failed")
+	newInterpreter := func() *Interpreter {
+		in := New(&bytes.Buffer{})
+		in.Register("fetch", func(args []Value) (Value, error) {
+			return Value{}, errFailed
+		}, TypeString)
+		return in
+	}
+
+	tests := []struct {
+		src string
+		msg string
+	}{
+		{"fetch()\n", `function "fetch" expects 1 args, got 0`},
+		{"fetch(1)\n", `argument 1 of "fetch" must be string, got int`},
+		{"fetch(\"a\", \"b\")\n", `function "fetch" expects 1 args, got 2`},
+		{"fetch(\"a\")\n", "request failed"},
+	}
+	for _, test := range tests {
+		err := newInterpreter().Execute(parse(t, test.src))
+		rtErr, ok := err.(*RuntimeError)
+		if !ok {
+			t.Errorf("%q: expected *RuntimeError, got %v", test.src, err)
+			continue
+		}
+		if rtErr.Msg != test.msg {
+			t.Errorf("%q: expected %q, got %q", test.src, test.msg, rtErr.Msg)
+		}
+	}
+
+	err := newInterpreter().Execute(parse(t, "fetch(\"a\")\n"))
+	if !errors.Is(err, errFailed) {
+		t.Errorf("expected error to wrap %v, got %v", errFailed, err)
+	}
+}
+
+func TestValueOf(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		repr string
+		out  interface{}
+	}{
+		{int64(-3), "-3", -3},
+		{uint8(7), "7", 7},
+		{float32(0.5), "0.5", 0.5},
+		{"hi", `"hi"`, "hi"},
+		{true, "true", true},
+		{[]interface{}{1, "a", []int{2}}, `[1, "a", [2]]`, []interface{}{1, "a", []interface{}{2}}},
+		{map[int]bool{2: false, 1: true}, "{1: true, 2: false}", map[interface{}]interface{}{1: true, 2: false}},
+		{Int(5), "5", 5},
+	}
+	for _, test := range tests {
+		v, err := ValueOf(test.in)
+		if err != nil {
+			t.Errorf("%#v: %v", test.in, err)
+			continue
+		}
+		if repr := v.v.repr(); repr != test.repr {
+			t.Errorf("%#v: expected %s, got %s", test.in, test.repr, repr)
+		}
+		if out := v.Interface(); !reflect.DeepEqual(out, test.out) {
+			t.Errorf("%#v: expected %#v, got %#v", test.in, test.out, out)
+		}
+	}
+
+	for _, x := range []interface{}{nil, struct{}{}, []Value{{}}, []interface{}{nil}, map[interface{}]int{[2]int{}: 1}} {
+		if _, err := ValueOf(x); err == nil {
+			t.Errorf("%#v: expected error", x)
+		}
+	}
+}
+
+func TestInterfaceCycles(t *testing.T) {
+	in := New(&bytes.Buffer{})
+	err := in.Execute(parse(t, "let xs = [1]\nappend(xs, xs)\nlet m = {}\nm[\"self\"] = m\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xs, _ := in.Global("xs")
+	list := xs.Interface().([]interface{})
+	if inner := list[1].([]interface{}); len(inner) != 2 || &inner[0] != &list[0] {
+		t.Errorf("expected the list to contain itself, got %v", inner)
+	}
+	m, _ := in.Global("m")
+	goMap := m.Interface().(map[interface{}]interface{})
+	if inner := goMap["self"].(map[interface{}]interface{}); reflect.ValueOf(inner).Pointer() != reflect.ValueOf(goMap).Pointer() {
+		t.Errorf("expected the map to contain itself")
+	}
+}
+
+func TestValueAccessorPanics(t *testing.T) {
+	defer func() {
+		r := recover()
+		if s, ok := r.(string); !ok || !strings.Contains(s, "Int of string value") {
+			t.Errorf("unexpected panic %v", r)
+		}
+	}()
+	String("a").Int()
+}
+
+func TestGlobals(t *testing.T) {
+	var out bytes.Buffer
+	in := New(&out)
+	in.SetGlobal("items", List(Int(2), Int(3)))
+	in.SetGlobal("factor", Float(1.5))
+
+	src := "let total = 0\nfor item in items {\n\ttotal = total + item * factor\n}\nappend(items, 4)\nfunc greet(name) {\n\treturn \"hi ${name}\"\n}\nfunc fail() {\n\treturn 1 / 0\n}\n"
+	err := in.Execute(parse(t, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total, ok := in.Global("total")
+	if !ok || total.Float() != 7.5 {
+		t.Errorf("unexpected total %v", total)
+	}
+	items, _ := in.Global("items")
+	if s := items.String(); s != "[2, 3, 4]" {
+		t.Errorf("expected the program to modify the list, got %s", s)
+	}
+	if _, ok := in.Global("item"); ok {
+		t.Error("expected loop variable not to be global")
+	}
+
+	result, err := in.Call("greet", String("go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != "hi go" {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	tests := []struct {
+		name string
+		args []Value
+		msg  string
+	}{
+		{"missing", nil, `undefined function "missing"`},
+		{"total", nil, "cannot call float"},
+		{"greet", []Value{{}}, `argument 1 of "greet" has no value`},
+		{"greet", nil, `-: function "greet" expects 1 args, got 0`},
+		{"fail", nil, "test.tik:10:9: integer division by zero"},
+	}
+	for _, test := range tests {
+		_, err := in.Call(test.name, test.args...)
+		if err == nil || err.Error() != test.msg {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.msg, err)
+		}
+	}
+
+	// the interpreter stays usable after a failed call
+	result, err = in.Call("greet", Int(1))
+	if err != nil || result.String() != "hi 1" {
+		t.Errorf("unexpected result %v, %v", result, err)
+	}
+}
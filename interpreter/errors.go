@@ -0,0 +1,52 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pseidemann/tik/source"
+)
+
+// maxTraceFrames is the number of frames shown by Traceback before eliding.
+const maxTraceFrames = 20
+
+// RuntimeError is an error which occurred while executing a program.
+type RuntimeError struct {
+	Pos   source.Pos // position of the failing node
+	Msg   string
+	Stack []Frame // tik call stack, innermost frame first
+	Err   error   // underlying error, from a registered Go function or a canceled context
+}
+
+// Frame is an entry of the tik call stack.
+type Frame struct {
+	Func string     // name of the function, "main" for the top level
+	Pos  source.Pos // position of execution inside the function
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Pos, e.Msg)
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// Traceback returns the error message followed by the call stack,
+// one frame per line, innermost frame first.
+func (e *RuntimeError) Traceback() string {
+	var b strings.Builder
+	b.WriteString(e.Error())
+	for i, f := range e.Stack {
+		if len(e.Stack) > maxTraceFrames && i == maxTraceFrames/2 {
+			skipped := len(e.Stack) - maxTraceFrames
+			fmt.Fprintf(&b, "\n\t... %d more frames ...", skipped)
+		}
+		if len(e.Stack) > maxTraceFrames && i >= maxTraceFrames/2 && i < len(e.Stack)-maxTraceFrames/2 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n\tat %s (%v)", f.Func, f.Pos)
+	}
+	return b.String()
+}
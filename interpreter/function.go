@@ -0,0 +1,47 @@
+package interpreter
+
+import "github.com/pseidemann/tik/ast"
+
+// function is the value of a function defined in tik or of a builtin.
+type function struct {
+	name    string // empty for anonymous functions
+	params  []*ast.Param
+	body    *ast.Block
+	env     *scope  // scope of the definition, which the function closes over
+	builtin builtin // implementation of a builtin, nil for functions defined in tik
+}
+
+func (f *function) displayName() string {
+	if f.name == "" {
+		return "<anonymous>"
+	}
+	return f.name
+}
+
+func (f *function) String() string {
+	if f.builtin != nil {
+		return "<builtin " + f.name + ">"
+	}
+	if f.name == "" {
+		return "<func>"
+	}
+	return "<func " + f.name + ">"
+}
+
+// newFunc creates a function value which closes over the current scope.
+func (in *Interpreter) newFunc(name string, params []*ast.Param, body *ast.Block) *variable {
+	return &variable{varType: TypeFunc, funcVal: &function{
+		name:   name,
+		params: params,
+		body:   body,
+		env:    in.context().scope,
+	}}
+}
+
+// callName returns the name of the called function for error messages.
+func callName(call *ast.FuncCall) string {
+	if ident, ok := call.Func.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return "<anonymous>"
+}
@@ -3,71 +3,136 @@ package interpreter
 
 import (
 	"bufio"
+	stdcontext "context"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
+	"strings"
 
 	"github.com/pseidemann/tik/ast"
+	"github.com/pseidemann/tik/source"
 )
 
-const maxStackSize = 1000
+// checkInterval is the number of evaluation steps between checks for cancellation.
+const checkInterval = 1000
 
 // Interpreter can execute an AST.
 type Interpreter struct {
-	stdout io.Writer
-	stack  contextStack
+	stdin   *bufio.Reader
+	stdout  *bufio.Writer // flushed when the execution ends
+	stderr  io.Writer
+	stack   contextStack
+	args    []string
+	globals *scope               // outermost scope of the program
+	natives map[string]*variable // functions added with Register
+	limits  Limits
+	used    usage
+	running bool // whether a program is executed, so the usage is counted already
+	// whether global declarations replace existing ones instead of failing, set by Eval
+	redeclare bool
+
+	ctx        stdcontext.Context // context of ExecuteContext, nil if there is none
+	untilCheck int                // number of steps until ctx is checked again
 }
 
+// context is the environment of a function call.
 type context struct {
-	vars  map[string]*variable
-	funcs map[string]*ast.FuncDef
+	scope    *scope     // innermost scope of the executed code
+	name     string     // name of the executed function
+	callSite source.Pos // position of the call which created the context
+}
+
+// scope holds the variables declared in a block. Variables which aren't declared in it
+// are looked up in the parent, the enclosing block or, for the outermost block of a
+// function, the scope in which the function was defined.
+type scope struct {
+	vars   map[string]*binding
+	parent *scope
 }
 
-type varType int
+// binding is a variable declared in a scope.
+type binding struct {
+	value    *variable
+	constant bool
+}
+
+// flow tells how the execution continues after a statement.
+type flow int
 
 const (
-	varNumber varType = iota
-	varString
+	flowNext flow = iota
+	flowReturn
+	flowBreak
+	flowContinue
 )
 
-type variable struct {
-	varType varType
-	intVal  int
-	strVal  string
-}
-
-func newContext() *context {
+func newContext(name string, parent *scope) *context {
 	return &context{
-		vars:  make(map[string]*variable),
-		funcs: make(map[string]*ast.FuncDef),
+		scope: newScope(parent),
+		name:  name,
 	}
 }
 
-func copyContext(prev *context) *context {
-	ctx := newContext()
-	for k, v := range prev.vars {
-		ctx.vars[k] = v
-	}
-	for k, v := range prev.funcs {
-		ctx.funcs[k] = v
+func newScope(parent *scope) *scope {
+	return &scope{
+		vars:   make(map[string]*binding),
+		parent: parent,
 	}
-	return ctx
 }
 
-// New creates an Interpreter.
+// Options configures the input and output of an Interpreter.
+// Nil readers are empty and nil writers discard everything written to them.
+type Options struct {
+	Stdin  io.Reader // read by the builtins input() and readline()
+	Stdout io.Writer // written by print()
+	Stderr io.Writer // written by eprint()
+}
+
+// New creates an Interpreter which prints to stdout.
 func New(stdout io.Writer) *Interpreter {
+	return NewWithOptions(Options{Stdout: stdout})
+}
+
+// NewWithOptions creates an Interpreter with the given input and output.
+// The output to Stdout is buffered and flushed when the execution ends,
+// before reading from Stdin or writing to Stderr, and by the builtin flush().
+func NewWithOptions(opts Options) *Interpreter {
+	if opts.Stdin == nil {
+		opts.Stdin = strings.NewReader("")
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = io.Discard
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = io.Discard
+	}
 	in := &Interpreter{
-		stdout: stdout,
+		stdin:   bufio.NewReader(opts.Stdin),
+		stdout:  bufio.NewWriter(opts.Stdout),
+		stderr:  opts.Stderr,
+		natives: make(map[string]*variable),
+		limits:  Limits{MaxCallDepth: DefaultMaxCallDepth},
 	}
-	in.stack.push(newContext())
+	main := newContext("main", nil)
+	in.globals = main.scope
+	in.stack.push(main)
 	return in
 }
 
-func (in *Interpreter) addContext() {
-	in.stack.push(copyContext(in.context()))
-	if in.stack.size() > maxStackSize {
-		panic("max stack size exceeded")
+// SetArgs sets the arguments for the program, which are available with the builtins argc() and arg(i).
+func (in *Interpreter) SetArgs(args []string) {
+	in.args = args
+}
+
+func (in *Interpreter) addContext(funcCall *ast.FuncCall, f *function) {
+	// the stack contains the calls and the main context, so its size is the depth of the new call
+	if in.stack.size() > in.limits.MaxCallDepth {
+		in.exceeded(funcCall, ErrCallDepthLimit, in.limits.MaxCallDepth)
 	}
+	ctx := newContext(f.displayName(), f.env)
+	ctx.callSite = funcCall.Pos()
+	in.stack.push(ctx)
 }
 
 func (in *Interpreter) removeContext() {
@@ -78,167 +143,659 @@ func (in *Interpreter) context() *context {
 	return in.stack.peek()
 }
 
-func (in *Interpreter) setVar(name string, variable *variable) {
-	in.context().vars[name] = variable
+// pushScope opens a new scope for the declarations of a block.
+func (in *Interpreter) pushScope() {
+	ctx := in.context()
+	ctx.scope = newScope(ctx.scope)
+}
+
+// popScope closes the scope opened by the last pushScope.
+func (in *Interpreter) popScope() {
+	ctx := in.context()
+	ctx.scope = ctx.scope.parent
+}
+
+// declareVar declares a variable in the current scope, shadowing variables of the
+// same name in the enclosing scopes. A name can only be declared once per scope,
+// except for global declarations in Eval.
+func (in *Interpreter) declareVar(n ast.Node, name string, variable *variable, constant bool) {
+	sc := in.context().scope
+	if _, ok := sc.vars[name]; ok && !(in.redeclare && sc == in.globals) {
+		panic(in.errorf(n, "%q already declared in this scope", name))
+	}
+	sc.vars[name] = &binding{value: variable, constant: constant}
+}
+
+// assignVar sets the nearest variable of the given name, which may be in an
+// enclosing scope. The variable must be declared and must not be a constant.
+func (in *Interpreter) assignVar(ident *ast.Ident, variable *variable) {
+	b, ok := in.lookupBinding(ident.Name)
+	if !ok {
+		panic(in.errorf(ident, "assignment to undeclared variable %q", ident.Name))
+	}
+	if b.constant {
+		panic(in.errorf(ident, "cannot assign to constant %q", ident.Name))
+	}
+	b.value = variable
 }
 
-func (in *Interpreter) getVar(name string) *variable {
-	v, ok := in.context().vars[name]
+func (in *Interpreter) getVar(ident *ast.Ident) *variable {
+	v, ok := in.lookup(ident.Name)
 	if !ok {
-		panic(fmt.Sprintf("undefined variable %q", name))
+		panic(in.errorf(ident, "undefined variable %q", ident.Name))
 	}
 	return v
 }
 
-func (in *Interpreter) setFunc(f *ast.FuncDef) {
-	in.context().funcs[f.Name] = f
+// lookup finds a variable in the current scope, its parents, the registered functions or the builtins.
+func (in *Interpreter) lookup(name string) (*variable, bool) {
+	if b, ok := in.lookupBinding(name); ok {
+		return b.value, true
+	}
+	if v, ok := in.natives[name]; ok {
+		return v, true
+	}
+	v, ok := builtinFuncs[name]
+	return v, ok
 }
 
-func (in *Interpreter) getFunc(name string) *ast.FuncDef {
-	f, ok := in.context().funcs[name]
-	if !ok {
-		panic(fmt.Sprintf("undefined function %q", name))
+// lookupBinding finds the nearest declaration of a variable, ignoring the builtins.
+func (in *Interpreter) lookupBinding(name string) (*binding, bool) {
+	for sc := in.context().scope; sc != nil; sc = sc.parent {
+		if b, ok := sc.vars[name]; ok {
+			return b, true
+		}
 	}
-	return f
+	return nil, false
+}
+
+// errorf creates a RuntimeError at the given node.
+// It is raised with panic and returned by Execute.
+func (in *Interpreter) errorf(n ast.Node, format string, args ...interface{}) *RuntimeError {
+	return &RuntimeError{
+		Pos:   n.Pos(),
+		Msg:   fmt.Sprintf(format, args...),
+		Stack: in.callStack(n.Pos()),
+	}
+}
+
+// callStack returns the current tik call stack, innermost frame first.
+func (in *Interpreter) callStack(pos source.Pos) []Frame {
+	frames := make([]Frame, 0, in.stack.size())
+	for i := in.stack.size() - 1; i >= 0; i-- {
+		ctx := in.stack.s[i]
+		frames = append(frames, Frame{Func: ctx.name, Pos: pos})
+		pos = ctx.callSite
+	}
+	return frames
 }
 
 // Execute interprets the given AST.
-func (in *Interpreter) Execute(root ast.Node) {
-	in.execAst(root)
+// Errors during execution are returned as *RuntimeError.
+func (in *Interpreter) Execute(root ast.Node) error {
+	return in.ExecuteContext(stdcontext.Background(), root)
 }
 
-func (in *Interpreter) execAst(n ast.Node) (vari *variable, returned bool) {
+// ExecuteContext interprets the given AST like Execute, but stops when ctx is done.
+// The execution is then aborted with a *RuntimeError at the position where it stopped,
+// which wraps the error of the context, e.g. context.Canceled.
+//
+// The context is checked regularly while statements and expressions are evaluated
+// and while values are formatted or compared. It is not checked while input() or
+// readline() wait for a line of stdin and during calls of registered Go functions,
+// so these can't be interrupted.
+func (in *Interpreter) ExecuteContext(ctx stdcontext.Context, root ast.Node) error {
+	in.ctx = ctx
+	in.untilCheck = 0
+	defer func() {
+		in.ctx = nil
+	}()
+	return in.run(func() {
+		if block, ok := root.(*ast.Block); ok {
+			// the program's declarations are global, so the root block has no scope of its own
+			in.execBlock(block)
+			return
+		}
+		in.execAst(root)
+	})
+}
+
+// Eval interprets the given AST like Execute. If the last statement of the root block is
+// an expression with a value, its representation is returned, e.g. for echoing it in a REPL.
+// Otherwise, result is empty.
+//
+// Unlike in Execute, a global variable or function may be declared again, which replaces
+// the previous declaration. This allows to redefine them in a REPL.
+func (in *Interpreter) Eval(root ast.Node) (result string, err error) {
+	redeclare := in.redeclare
+	in.redeclare = true
+	defer func() {
+		in.redeclare = redeclare
+	}()
+	err = in.run(func() {
+		block, ok := root.(*ast.Block)
+		if !ok || len(block.Stmts) == 0 {
+			in.execAst(root)
+			return
+		}
+		last := len(block.Stmts) - 1
+		for _, stmt := range block.Stmts[:last] {
+			if _, f := in.execAst(stmt); f != flowNext {
+				return
+			}
+		}
+		if !isExpr(block.Stmts[last]) {
+			in.execAst(block.Stmts[last])
+			return
+		}
+		if v := in.execExpr(block.Stmts[last]); v != nil {
+			f := in.formatter(block.Stmts[last], func(length int) {
+				in.checkStringLen(block.Stmts[last], length)
+			})
+			f.format(v, true)
+			in.checkStringLen(block.Stmts[last], f.b.Len())
+			result = f.b.String()
+		}
+	})
+	return result, err
+}
+
+// run calls f and turns a raised RuntimeError into a returned error.
+func (in *Interpreter) run(f func()) (err error) {
+	if !in.running {
+		// start counting the usage, unless this is a call from a registered function
+		in.running = true
+		in.used = usage{}
+		defer func() {
+			in.running = false
+			if flushErr := in.stdout.Flush(); err == nil {
+				err = flushErr
+			}
+		}()
+	}
+	depth := in.stack.size()
+	sc := in.context().scope
+	defer func() {
+		if r := recover(); r != nil {
+			rtErr, ok := r.(*RuntimeError)
+			if !ok {
+				panic(r)
+			}
+			// drop the contexts of the aborted function calls
+			for in.stack.size() > depth {
+				in.removeContext()
+			}
+			// leave the scopes of the aborted blocks
+			in.context().scope = sc
+			err = rtErr
+		}
+	}()
+	f()
+	return nil
+}
+
+// isExpr reports whether the node is an expression, as opposed to other statements.
+func isExpr(n ast.Node) bool {
+	switch n.(type) {
+	case *ast.FuncDef, *ast.Decl, *ast.Assign, *ast.Return, *ast.If, *ast.For,
+		*ast.ForIn, *ast.Break, *ast.Continue, *ast.Block:
+		return false
+	}
+	return true
+}
+
+// step is called for each evaluated statement and expression.
+// It aborts the execution at the given node if the step limit is exceeded or the context is done.
+func (in *Interpreter) step(n ast.Node) {
+	in.used.steps++
+	if in.limits.MaxSteps > 0 && in.used.steps > in.limits.MaxSteps {
+		in.exceeded(n, ErrStepLimit, in.limits.MaxSteps)
+	}
+	if in.ctx == nil {
+		return
+	}
+	in.untilCheck--
+	if in.untilCheck > 0 {
+		return
+	}
+	in.untilCheck = checkInterval
+	select {
+	case <-in.ctx.Done():
+		rtErr := in.errorf(n, "execution canceled: %v", in.ctx.Err())
+		rtErr.Err = in.ctx.Err()
+		panic(rtErr)
+	default:
+	}
+}
+
+func (in *Interpreter) execAst(n ast.Node) (*variable, flow) {
+	in.step(n)
 	switch v := n.(type) {
 	case *ast.FuncDef:
-		in.setFunc(v)
-	case *ast.FuncCall:
-		in.execFuncCall(v)
+		in.declareVar(v, v.Name, in.newFunc(v.Name, v.Params, v.Body), false)
+	case *ast.Decl:
+		in.declareVar(v.Name, v.Name.Name, in.execOperand(v.Value), v.Const)
 	case *ast.Assign:
 		in.execAssign(v)
 	case *ast.Return:
+		if v.Value == nil {
+			return nil, flowReturn
+		}
 		result := in.execExpr(v.Value)
-		return result, true
+		return result, flowReturn
+	case *ast.If:
+		return in.execIf(v)
+	case *ast.For:
+		return in.execFor(v)
+	case *ast.ForIn:
+		return in.execForIn(v)
+	case *ast.Break:
+		return nil, flowBreak
+	case *ast.Continue:
+		return nil, flowContinue
 	case *ast.Block:
-		for _, child := range n.Children() {
-			vari, abort := in.execAst(child)
-			if abort {
-				return vari, false
-			}
-		}
+		in.pushScope()
+		vari, f := in.execBlock(v)
+		in.popScope()
+		return vari, f
 	default:
-		panic("unknown node")
+		// expression statement, like a function call
+		in.execExpr(n)
 	}
-	return nil, false
+	return nil, flowNext
 }
 
-func (in *Interpreter) execFuncCall(funcCall *ast.FuncCall) *variable {
-	var retVal *variable
-	switch funcCall.Name {
-	case "print":
-		buf := bufio.NewWriter(in.stdout)
-		lastIdx := len(funcCall.Args) - 1
-		for i, child := range funcCall.Args {
-			var str string
-			switch v := child.(type) {
-			case *ast.String:
-				str = v.Str
-			case *ast.Operation:
-				num := in.execExpr(v)
-				str = strconv.Itoa(num.intVal)
-			case *ast.Ident:
-				vari := in.getVar(v.Name)
-				switch vari.varType {
-				case varNumber:
-					str = strconv.Itoa(vari.intVal)
-				case varString:
-					str = vari.strVal
-				default:
-					panic("unknown variable type")
-				}
-			case *ast.FuncCall:
-				vari := in.execFuncCall(v)
-				if vari == nil {
-					continue
-				}
-				switch vari.varType {
-				case varNumber:
-					str = strconv.Itoa(vari.intVal)
-				case varString:
-					str = vari.strVal
-				default:
-					panic("unknown variable type")
-				}
-			default:
-				panic("unknown argument type")
-			}
-			buf.WriteString(str)
-			if i < lastIdx {
-				buf.WriteRune(' ')
-			}
+// execBlock executes the statements of a block in the current scope.
+func (in *Interpreter) execBlock(b *ast.Block) (*variable, flow) {
+	for _, stmt := range b.Stmts {
+		vari, f := in.execAst(stmt)
+		if f != flowNext {
+			return vari, f
+		}
+	}
+	return nil, flowNext
+}
+
+func (in *Interpreter) execIf(n *ast.If) (*variable, flow) {
+	if in.isTrue(n.Cond, in.execExpr(n.Cond)) {
+		return in.execAst(n.Then)
+	}
+	if n.Else != nil {
+		return in.execAst(n.Else)
+	}
+	return nil, flowNext
+}
+
+// execFor runs a for loop. Variables declared by the init statement are scoped to the loop.
+func (in *Interpreter) execFor(n *ast.For) (*variable, flow) {
+	in.pushScope()
+	defer in.popScope()
+	if n.Init != nil {
+		in.execAst(n.Init)
+	}
+	for {
+		if n.Cond != nil && !in.isTrue(n.Cond, in.execExpr(n.Cond)) {
+			break
+		}
+		vari, f := in.execAst(n.Body)
+		if f == flowReturn {
+			return vari, f
+		}
+		if f == flowBreak {
+			break
+		}
+		if n.Post != nil {
+			in.execAst(n.Post)
+		}
+	}
+	return nil, flowNext
+}
+
+// execForIn runs the body for each element. The elements are determined before the
+// first iteration, so modifying the iterated value in the body doesn't affect the loop.
+func (in *Interpreter) execForIn(n *ast.ForIn) (*variable, flow) {
+	iter := in.execOperand(n.Iter)
+	var firsts, seconds []*variable
+	switch iter.varType {
+	case TypeList:
+		for i, elem := range iter.listVal {
+			firsts = append(firsts, &variable{varType: TypeInt, intVal: i})
+			seconds = append(seconds, elem)
+		}
+	case TypeString:
+		for i, r := range []rune(iter.strVal) {
+			firsts = append(firsts, &variable{varType: TypeInt, intVal: i})
+			seconds = append(seconds, newString(string(r)))
+		}
+	case TypeMap:
+		for _, e := range iter.mapVal.entries {
+			firsts = append(firsts, e.key)
+			seconds = append(seconds, e.value)
 		}
-		buf.WriteRune('\n')
-		buf.Flush()
 	default:
-		f := in.getFunc(funcCall.Name)
-		in.addContext()
-		if len(funcCall.Args) != len(f.Params) {
-			panic("number of defined args and passed args don't match")
+		panic(in.errorf(n.Iter, "cannot iterate over %v", iter.varType))
+	}
+
+	for i := range firsts {
+		// each iteration has its own variables, which closures in the body can capture
+		in.pushScope()
+		switch {
+		case len(n.Vars) == 2:
+			in.declareVar(n.Vars[0], n.Vars[0].Name, firsts[i], false)
+			in.declareVar(n.Vars[1], n.Vars[1].Name, seconds[i], false)
+		case iter.varType == TypeMap:
+			in.declareVar(n.Vars[0], n.Vars[0].Name, firsts[i], false)
+		default:
+			in.declareVar(n.Vars[0], n.Vars[0].Name, seconds[i], false)
+		}
+		vari, f := in.execBlock(n.Body)
+		in.popScope()
+		if f == flowReturn {
+			return vari, f
 		}
-		for i, arg := range funcCall.Args {
-			name := f.Params[i].Name
-			in.setVar(name, in.execExpr(arg))
+		if f == flowBreak {
+			break
 		}
-		retVal, _ = in.execAst(f.Body)
-		in.removeContext()
 	}
+	return nil, flowNext
+}
+
+// isTrue reports whether the value of a condition is considered true.
+// Numbers are true if they are not zero, strings, lists and maps if they are not empty.
+func (in *Interpreter) isTrue(cond ast.Node, v *variable) bool {
+	if v == nil {
+		panic(in.errorf(cond, "condition has no value"))
+	}
+	switch v.varType {
+	case TypeInt:
+		return v.intVal != 0
+	case TypeFloat:
+		return v.floatVal != 0
+	case TypeString:
+		return v.strVal != ""
+	case TypeBool:
+		return v.boolVal
+	case TypeList:
+		return len(v.listVal) > 0
+	case TypeMap:
+		return len(v.mapVal.entries) > 0
+	case TypeFunc:
+		return true
+	default:
+		panic(in.errorf(cond, "unknown variable type"))
+	}
+}
+
+func (in *Interpreter) execFuncCall(funcCall *ast.FuncCall) *variable {
+	var fn *variable
+	if ident, ok := funcCall.Func.(*ast.Ident); ok {
+		fn, ok = in.lookup(ident.Name)
+		if !ok {
+			panic(in.errorf(funcCall, "undefined function %q", ident.Name))
+		}
+	} else {
+		fn = in.execOperand(funcCall.Func)
+	}
+	if fn.varType != TypeFunc {
+		panic(in.errorf(funcCall.Func, "cannot call %v", fn.varType))
+	}
+	f := fn.funcVal
+
+	if f.builtin == nil {
+		// check the arity before evaluating the arguments
+		in.checkArity(funcCall, f, len(funcCall.Args))
+	}
+	args := make([]*variable, len(funcCall.Args))
+	for i, arg := range funcCall.Args {
+		args[i] = in.execExpr(arg)
+	}
+	return in.callFunc(funcCall, f, args)
+}
+
+// checkArity makes sure that a function defined in tik is called with the number of its parameters.
+func (in *Interpreter) checkArity(funcCall *ast.FuncCall, f *function, n int) {
+	if n != len(f.params) {
+		panic(in.errorf(funcCall, "function %q expects %d args, got %d", f.displayName(), len(f.params), n))
+	}
+}
+
+// callFunc calls a function with evaluated arguments. The arity of functions
+// defined in tik must be checked before, builtins check their arguments themselves.
+func (in *Interpreter) callFunc(funcCall *ast.FuncCall, f *function, args []*variable) *variable {
+	if f.builtin != nil {
+		return f.builtin(in, funcCall, args)
+	}
+	in.addContext(funcCall, f)
+	for i, arg := range args {
+		in.declareVar(f.params[i], f.params[i].Name, arg, false)
+	}
+	// the parameters are in the same scope as the declarations of the body
+	retVal, _ := in.execBlock(f.body)
+	in.removeContext()
 
 	return retVal
 }
 
 func (in *Interpreter) execExpr(n ast.Node) *variable {
+	in.step(n)
 	switch v := n.(type) {
 	case *ast.Operation:
 		return in.execOp(v)
+	case *ast.UnaryOperation:
+		return in.execUnaryOp(v)
+	case *ast.Bool:
+		return &variable{varType: TypeBool, boolVal: v.Value}
 	case *ast.Number:
+		if strings.ContainsAny(v.Num, ".eE") {
+			f, err := strconv.ParseFloat(v.Num, 64)
+			if err != nil {
+				panic(in.errorf(v, "invalid number %s", v.Num))
+			}
+			return &variable{varType: TypeFloat, floatVal: f}
+		}
 		n, err := strconv.Atoi(v.Num)
 		if err != nil {
-			panic(err)
+			panic(in.errorf(v, "invalid number %s", v.Num))
 		}
-		return &variable{varType: varNumber, intVal: n}
+		return &variable{varType: TypeInt, intVal: n}
 	case *ast.Ident:
-		return in.getVar(v.Name)
+		return in.getVar(v)
 	case *ast.String:
-		return &variable{varType: varString, strVal: v.Str}
+		return &variable{varType: TypeString, strVal: v.Str}
+	case *ast.FuncLit:
+		return in.newFunc("", v.Params, v.Body)
+	case *ast.List:
+		return in.execList(v)
+	case *ast.Map:
+		return in.execMap(v)
+	case *ast.Index:
+		return in.execIndex(v)
+	case *ast.Slice:
+		return in.execSlice(v)
+	case *ast.Interpolation:
+		f := in.formatter(v, func(length int) {
+			in.checkStringLen(v, length)
+		})
+		for _, part := range v.Parts {
+			// values are converted like print does
+			f.format(in.execOperand(part), false)
+		}
+		in.checkStringLen(v, f.b.Len())
+		return &variable{varType: TypeString, strVal: f.b.String()}
 	case *ast.FuncCall:
 		return in.execFuncCall(v)
 	default:
-		panic(fmt.Sprintf("unknown expression %v", n))
+		panic(in.errorf(n, "unknown expression %v", n))
+	}
+}
+
+// formatter returns a formatter which counts a step for each formatted value and
+// calls check with the length so far, so that formatting a deeply nested value
+// stops at the limits and when the execution is canceled.
+func (in *Interpreter) formatter(n ast.Node, check func(length int)) *formatter {
+	return &formatter{visit: func(length int) {
+		in.step(n)
+		check(length)
+	}}
+}
+
+// equals reports whether both values are equal, counting a step for each compared list or map.
+func (in *Interpreter) equals(n ast.Node, v, other *variable) bool {
+	c := comparison{visit: func() {
+		in.step(n)
+	}}
+	return c.equals(v, other)
+}
+
+// execOperand evaluates an expression which must have a value.
+func (in *Interpreter) execOperand(n ast.Node) *variable {
+	v := in.execExpr(n)
+	if v == nil {
+		panic(in.errorf(n, "expression has no value"))
 	}
+	return v
 }
 
 func (in *Interpreter) execOp(op *ast.Operation) *variable {
+	// the logical operators only evaluate the right side if needed
+	switch op.OpType {
+	case ast.OpAnd:
+		v := in.isTrue(op.Left, in.execExpr(op.Left)) && in.isTrue(op.Right, in.execExpr(op.Right))
+		return &variable{varType: TypeBool, boolVal: v}
+	case ast.OpOr:
+		v := in.isTrue(op.Left, in.execExpr(op.Left)) || in.isTrue(op.Right, in.execExpr(op.Right))
+		return &variable{varType: TypeBool, boolVal: v}
+	}
+
+	left := in.execOperand(op.Left)
+	right := in.execOperand(op.Right)
+
 	switch op.OpType {
+	case ast.OpEq:
+		return &variable{varType: TypeBool, boolVal: in.equals(op, left, right)}
+	case ast.OpNe:
+		return &variable{varType: TypeBool, boolVal: !in.equals(op, left, right)}
+	case ast.OpLt, ast.OpLe, ast.OpGt, ast.OpGe:
+		if left.varType == TypeString && right.varType == TypeString {
+			v := compareInts(op.OpType, strings.Compare(left.strVal, right.strVal), 0)
+			return &variable{varType: TypeBool, boolVal: v}
+		}
+		if !left.isNumber() || !right.isNumber() {
+			panic(in.errorf(op, "cannot compare %v and %v with '%v'", left.varType, right.varType, op.OpType))
+		}
+		var v bool
+		if left.varType == TypeInt && right.varType == TypeInt {
+			v = compareInts(op.OpType, left.intVal, right.intVal)
+		} else {
+			v = compareFloats(op.OpType, left.toFloat(), right.toFloat())
+		}
+		return &variable{varType: TypeBool, boolVal: v}
+	case ast.OpAdd, ast.OpSub, ast.OpMul, ast.OpDiv, ast.OpMod:
+		if op.OpType == ast.OpAdd && left.varType == TypeString && right.varType == TypeString {
+			in.checkStringLen(op, len(left.strVal)+len(right.strVal))
+			return &variable{varType: TypeString, strVal: left.strVal + right.strVal}
+		}
+		if !left.isNumber() || !right.isNumber() {
+			panic(in.errorf(op, "cannot apply '%v' to %v and %v", op.OpType, left.varType, right.varType))
+		}
+		// ints stay ints, including the division, which truncates like in Go.
+		// As soon as one operand is a float, the operation is done with floats.
+		if left.varType == TypeFloat || right.varType == TypeFloat {
+			v := arithFloats(op.OpType, left.toFloat(), right.toFloat())
+			return &variable{varType: TypeFloat, floatVal: v}
+		}
+		if right.intVal == 0 && (op.OpType == ast.OpDiv || op.OpType == ast.OpMod) {
+			panic(in.errorf(op, "integer division by zero"))
+		}
+		v := arithInts(op.OpType, left.intVal, right.intVal)
+		return &variable{varType: TypeInt, intVal: v}
+	default:
+		panic(in.errorf(op, "unknown operation %v", op))
+	}
+}
+
+func compareInts(opType ast.OpType, left, right int) bool {
+	switch opType {
+	case ast.OpLt:
+		return left < right
+	case ast.OpLe:
+		return left <= right
+	case ast.OpGt:
+		return left > right
+	default:
+		return left >= right
+	}
+}
+
+func compareFloats(opType ast.OpType, left, right float64) bool {
+	switch opType {
+	case ast.OpLt:
+		return left < right
+	case ast.OpLe:
+		return left <= right
+	case ast.OpGt:
+		return left > right
+	default:
+		return left >= right
+	}
+}
+
+func arithInts(opType ast.OpType, left, right int) int {
+	switch opType {
+	case ast.OpAdd:
+		return left + right
+	case ast.OpSub:
+		return left - right
+	case ast.OpMul:
+		return left * right
+	case ast.OpMod:
+		return left % right
+	default:
+		return left / right
+	}
+}
+
+func arithFloats(opType ast.OpType, left, right float64) float64 {
+	switch opType {
 	case ast.OpAdd:
-		v := in.execExpr(op.Left).intVal + in.execExpr(op.Right).intVal
-		return &variable{varType: varNumber, intVal: v}
+		return left + right
 	case ast.OpSub:
-		v := in.execExpr(op.Left).intVal - in.execExpr(op.Right).intVal
-		return &variable{varType: varNumber, intVal: v}
+		return left - right
 	case ast.OpMul:
-		v := in.execExpr(op.Left).intVal * in.execExpr(op.Right).intVal
-		return &variable{varType: varNumber, intVal: v}
-	case ast.OpDiv:
-		v := in.execExpr(op.Left).intVal / in.execExpr(op.Right).intVal
-		return &variable{varType: varNumber, intVal: v}
+		return left * right
+	case ast.OpMod:
+		return math.Mod(left, right)
+	default:
+		return left / right
+	}
+}
+
+func (in *Interpreter) execUnaryOp(op *ast.UnaryOperation) *variable {
+	switch op.OpType {
+	case ast.OpNot:
+		return &variable{varType: TypeBool, boolVal: !in.isTrue(op.Operand, in.execExpr(op.Operand))}
+	case ast.OpNeg:
+		v := in.execOperand(op.Operand)
+		switch v.varType {
+		case TypeInt:
+			return &variable{varType: TypeInt, intVal: -v.intVal}
+		case TypeFloat:
+			return &variable{varType: TypeFloat, floatVal: -v.floatVal}
+		default:
+			panic(in.errorf(op, "cannot apply '%v' to %v", op.OpType, v.varType))
+		}
 	default:
-		panic(fmt.Sprintf("unknown operation %v", op))
+		panic(in.errorf(op, "unknown operation %v", op))
 	}
 }
 
 func (in *Interpreter) execAssign(n *ast.Assign) {
-	ident, ok := n.Left.(*ast.Ident)
-	if !ok {
-		panic("expected identifier on left side of assignment")
+	switch left := n.Left.(type) {
+	case *ast.Ident:
+		in.assignVar(left, in.execOperand(n.Right))
+	case *ast.Index:
+		in.assignIndex(left, in.execOperand(n.Right))
+	default:
+		panic(in.errorf(n, "expected identifier or index on left side of assignment"))
 	}
-	in.setVar(ident.Name, in.execExpr(n.Right))
 }
@@ -2,13 +2,127 @@ package interpreter
 
 import (
 	"bytes"
+	stdcontext "context"
+	"errors"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/pseidemann/tik/ast"
 	"github.com/pseidemann/tik/lexer"
 	"github.com/pseidemann/tik/parser"
+	"github.com/pseidemann/tik/source"
 )
 
+func TestBooleans(t *testing.T) {
+	f, err := os.Open("../testdata/booleans.tik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := lexer.New(f)
+	par := parser.New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := New(&out)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "true false true false true false\nfalse true false true\ntrue true false false\ntrue\n2 -6\nfalse true\ndone\n"
+
+	if out.String() != expected {
+		t.Error("unexpected output")
+	}
+}
+
+func TestClosures(t *testing.T) {
+	f, err := os.Open("../testdata/closures.tik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := lexer.New(f)
+	par := parser.New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := New(&out)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `5 15
+[1, 4, 9]
+["A", "B"]
+global
+3
+42 <builtin len> <func adder> <func>
+true false
+[<builtin upper>, <builtin print>] true
+`
+
+	if out.String() != expected {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestFloats(t *testing.T) {
+	f, err := os.Open("../testdata/floats.tik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := lexer.New(f)
+	par := parser.New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := New(&out)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "3.5\n3 3.5\n5.0\n1 -1 1.5\n1000.0 0.0025 100.0\n0.30000000000000004\n-1.5 2.5\ntrue true false\n1e+21 0.3333333333333333\n2 2.5\n"
+
+	if out.String() != expected {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestForLoop(t *testing.T) {
+	f, err := os.Open("../testdata/for_loop.tik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := lexer.New(f)
+	par := parser.New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := New(&out)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "10\n3\n2\n1\n2\n6\n"
+
+	if out.String() != expected {
+		t.Error("unexpected output")
+	}
+}
+
 func TestFuncArgs(t *testing.T) {
 	f, err := os.Open("../testdata/func_args.tik")
 	if err != nil {
@@ -16,10 +130,16 @@ func TestFuncArgs(t *testing.T) {
 	}
 	lex := lexer.New(f)
 	par := parser.New(lex)
-	a := par.CreateAST()
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
 	var out bytes.Buffer
 	in := New(&out)
-	in.Execute(a)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	expected := "1 2\n"
 
@@ -35,10 +155,16 @@ func TestFuncReturn(t *testing.T) {
 	}
 	lex := lexer.New(f)
 	par := parser.New(lex)
-	a := par.CreateAST()
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
 	var out bytes.Buffer
 	in := New(&out)
-	in.Execute(a)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	expected := "4\n"
 
@@ -54,12 +180,18 @@ func TestFuncScope(t *testing.T) {
 	}
 	lex := lexer.New(f)
 	par := parser.New(lex)
-	a := par.CreateAST()
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
 	var out bytes.Buffer
 	in := New(&out)
-	in.Execute(a)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	expected := "1 2 3\n"
+	expected := "1 2 3\n4 2\n"
 
 	if out.String() != expected {
 		t.Error("unexpected output")
@@ -73,10 +205,16 @@ func TestFuncSimple(t *testing.T) {
 	}
 	lex := lexer.New(f)
 	par := parser.New(lex)
-	a := par.CreateAST()
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
 	var out bytes.Buffer
 	in := New(&out)
-	in.Execute(a)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	expected := "Hello, world!\n"
 
@@ -85,6 +223,127 @@ func TestFuncSimple(t *testing.T) {
 	}
 }
 
+func TestIfElse(t *testing.T) {
+	f, err := os.Open("../testdata/if_else.tik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := lexer.New(f)
+	par := parser.New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := New(&out)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "zero nonzero\na b none\n"
+
+	if out.String() != expected {
+		t.Error("unexpected output")
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	f, err := os.Open("../testdata/interpolation.tik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := lexer.New(f)
+	par := parser.New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := New(&out)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "hello tik, you are 42\n3.0true\nnested inner tik done\nhi you! costs ${5}\n"
+
+	if out.String() != expected {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestLists(t *testing.T) {
+	f, err := os.Open("../testdata/lists.tik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := lexer.New(f)
+	par := parser.New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := New(&out)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[1, 2.5, "three", [4, 5]] 4 0
+1 [4, 5] 5 three
+[10, 2.5, "three", ["four", 5]]
+[1, 2] [0, 1] [3, 4] [3, 4] [0, 1, 2, 3, 4] []
+[0, 1, 2, 3, 4, 5, 6] 7
+6 [0, 1, 2, 3, 4, 5]
+0 99
+é o éll
+true false true
+15
+`
+
+	if out.String() != expected {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestMaps(t *testing.T) {
+	f, err := os.Open("../testdata/maps.tik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := lexer.New(f)
+	par := parser.New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := New(&out)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"bob": 31, "alice": 27} 2 27
+["bob", "alice", "carol"] true false
+{"bob": 32, "carol": 45}
+bob 32
+carol 45
+{"a": 3, "b": 2, "c": 1}
+int float bool true true
+0 h
+1 i
+1
+3
+`
+
+	if out.String() != expected {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
 func TestMath(t *testing.T) {
 	f, err := os.Open("../testdata/math.tik")
 	if err != nil {
@@ -92,10 +351,16 @@ func TestMath(t *testing.T) {
 	}
 	lex := lexer.New(f)
 	par := parser.New(lex)
-	a := par.CreateAST()
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
 	var out bytes.Buffer
 	in := New(&out)
-	in.Execute(a)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	expected := "70\n"
 
@@ -111,10 +376,16 @@ func TestPrint(t *testing.T) {
 	}
 	lex := lexer.New(f)
 	par := parser.New(lex)
-	a := par.CreateAST()
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
 	var out bytes.Buffer
 	in := New(&out)
-	in.Execute(a)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	expected := "hello\nworld1 world2\nworld3 7\n"
 
@@ -123,6 +394,81 @@ func TestPrint(t *testing.T) {
 	}
 }
 
+func TestScoping(t *testing.T) {
+	f, err := os.Open("../testdata/scoping.tik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := lexer.New(f)
+	par := parser.New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := New(&out)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "2\nstill local\nglobal\n1 2\n5\nyes\n2 3\n1\n2\n4\n6\n[10] 10\n"
+
+	if out.String() != expected {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestStringFuncs(t *testing.T) {
+	f, err := os.Open("../testdata/string_funcs.tik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := lexer.New(f)
+	par := parser.New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := New(&out)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Hello, wörld! 12 HELLO, WÖRLD hello, wörld\nwörld 7 -1 true\n[\"a\", \"b\", \"c\"] 3 a-b-c\nbbb [x] ababab\ntrue true true false\n"
+
+	if out.String() != expected {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestStrings(t *testing.T) {
+	f, err := os.Open("../testdata/strings.tik")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := lexer.New(f)
+	par := parser.New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	in := New(&out)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "say \"hi\"\tand\\nbye\ncaf\u00e9 \U0001F600\nraw \\n \"quoted\"\nfirst\nsecond\na\\b\n"
+
+	if out.String() != expected {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
 func TestVariables(t *testing.T) {
 	f, err := os.Open("../testdata/variables.tik")
 	if err != nil {
@@ -130,10 +476,16 @@ func TestVariables(t *testing.T) {
 	}
 	lex := lexer.New(f)
 	par := parser.New(lex)
-	a := par.CreateAST()
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
 	var out bytes.Buffer
 	in := New(&out)
-	in.Execute(a)
+	err = in.Execute(a)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	expected := "3 8 11\n"
 
@@ -141,3 +493,254 @@ func TestVariables(t *testing.T) {
 		t.Error("unexpected output")
 	}
 }
+
+func TestRuntimeError(t *testing.T) {
+	src := "func inner() {\n\tprint(missing)\n}\nfunc outer() {\n\tinner()\n}\nouter()\n"
+	a := parse(t, src)
+	in := New(&bytes.Buffer{})
+	err := in.Execute(a)
+
+	rtErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected RuntimeError, got %v", err)
+	}
+	if rtErr.Error() != `test.tik:2:8: undefined variable "missing"` {
+		t.Errorf("unexpected error %q", rtErr.Error())
+	}
+
+	expected := []Frame{
+		{Func: "inner", Pos: rtErr.Pos},
+		{Func: "outer", Pos: pos(5, 2, 49)},
+		{Func: "main", Pos: pos(7, 1, 59)},
+	}
+	if len(rtErr.Stack) != len(expected) {
+		t.Fatalf("unexpected stack %v", rtErr.Stack)
+	}
+	for i, f := range expected {
+		if rtErr.Stack[i] != f {
+			t.Errorf("frame %d: expected %v got %v", i, f, rtErr.Stack[i])
+		}
+	}
+
+	// the interpreter must be usable after an error
+	if in.stack.size() != 1 {
+		t.Errorf("expected only the main context, got %d", in.stack.size())
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		msg string
+	}{
+		{"print(x)\n", `undefined variable "x"`},
+		{"foo()\n", `undefined function "foo"`},
+		{"func foo(a) {\n}\nfoo(1, 2)\n", `function "foo" expects 1 args, got 2`},
+		{"func foo() {\n\tfoo()\n}\nfoo()\n", "call depth limit exceeded (limit 1000)"},
+		{"let x = \"a\" + 1\n", "cannot apply '+' to string and int"},
+		{"let x = \"a\" - \"b\"\n", "cannot apply '-' to string and string"},
+		{"let x = 2 * true\n", "cannot apply '*' to int and bool"},
+		{"let x = 1.5 % \"a\"\n", "cannot apply '%' to float and string"},
+		{"let x = -\"a\"\n", "cannot apply '-' to string"},
+		{"let x = 1 / 0\n", "integer division by zero"},
+		{"let x = [1, 2]\nprint(x[2])\n", "index 2 out of range for length 2"},
+		{"let x = [1, 2]\nprint(x[-3])\n", "index -3 out of range for length 2"},
+		{"let x = [1, 2]\nprint(x[\"a\"])\n", "index must be int, got string"},
+		{"let x = [1, 2]\nprint(x[1:3])\n", "slice bounds [1:3] out of range for length 2"},
+		{"let x = [1, 2]\nprint(x[2:1])\n", "slice bounds [2:1] out of range for length 2"},
+		{"let x = 5\nprint(x[0])\n", "cannot index int"},
+		{"let x = \"abc\"\nx[0] = \"b\"\n", "cannot assign to index of string"},
+		{"pop([])\n", "pop from empty list"},
+		{"let m = {\"a\": 1}\nprint(m[\"b\"])\n", `key "b" not found in map`},
+		{"let m = {[1]: 1}\n", "invalid map key type list"},
+		{"has(1, 2)\n", `argument 1 of "has" must be map, got int`},
+		{"for x in 5 {\n}\n", "cannot iterate over int"},
+		{"let x = 1\nx(2)\n", "cannot call int"},
+		{"func f() {\n\tc = 1\n}\nf()\n", `assignment to undeclared variable "c"`},
+		{"func f() {\n\tlet c = 1\n}\nf()\nprint(c)\n", `undefined variable "c"`},
+		{"let f = func(a) {\n}\nf()\n", `function "<anonymous>" expects 1 args, got 0`},
+		{"append([])\n", `function "append" expects at least 2 args, got 1`},
+		{"let x = 0\nprint(5 % x)\n", "integer division by zero"},
+		{"const x = 1\nx = 2\n", `cannot assign to constant "x"`},
+		{"func f() {\n}\nlet y = 1\ny = f()\n", "expression has no value"},
+		{"let x = 1\nlet x = 2\n", `"x" already declared in this scope`},
+		{"func f(a) {\n\tlet a = 1\n}\nf(1)\n", `"a" already declared in this scope`},
+		{"if true {\n\tlet x = 1\n}\nprint(x)\n", `undefined variable "x"`},
+		{"for let i = 0; i < 1; i = i + 1 {\n}\nprint(i)\n", `undefined variable "i"`},
+		{"upper(1)\n", `argument 1 of "upper" must be string, got int`},
+		{"len(true)\n", `argument 1 of "len" must be string, list or map, got bool`},
+		{"substr(\"abc\", 1, 4)\n", "end 4 out of range [1, 3]"},
+		{"repeat(\"a\", -1)\n", "negative repeat count -1"},
+		{"repeat(\"ab\", 9223372036854775807)\n", "repeat count 9223372036854775807 too large"},
+		{"join(split(\"a\", \"\"), 1)\n", `argument 2 of "join" must be string, got int`},
+	}
+
+	for _, test := range tests {
+		in := New(&bytes.Buffer{})
+		err := in.Execute(parse(t, test.src))
+		rtErr, ok := err.(*RuntimeError)
+		if !ok {
+			t.Errorf("%q: expected RuntimeError, got %v", test.src, err)
+			continue
+		}
+		if rtErr.Msg != test.msg {
+			t.Errorf("%q: expected %q, got %q", test.src, test.msg, rtErr.Msg)
+		}
+	}
+}
+
+func TestCycles(t *testing.T) {
+	var out bytes.Buffer
+	in := New(&out)
+	src := "let a = [0]\na[0] = a\nlet b = [1]\nappend(b, b, [b])\nlet c = [0]\nc[0] = c\nprint(a, b)\nprint(\"${a}\", a == a, a == c, a == b, b == b)\nlet m = {}\nm[\"a\"] = m\nm[\"b\"] = [m]\nlet n = {\"a\": 1}\nn[\"a\"] = n\nprint(m, m == m, n == n, m == n)\n"
+	err := in.Execute(parse(t, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[[...]] [1, [...], [[...]]]\n[[...]] true true false true\n{\"a\": {...}, \"b\": [{...}]} true true false\n"
+	if out.String() != expected {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestLimits(t *testing.T) {
+	nested := "let a = [1]\nfor let i = 0; i < 26; i = i + 1 {\n\ta = [a, a]\n}\n"
+	nestedLimits := Limits{MaxSteps: 100000, MaxElements: 200, MaxOutputBytes: 1000, MaxStringLen: 1000}
+	tests := []struct {
+		limits Limits
+		src    string
+		err    error
+		msg    string
+		out    string
+	}{
+		{Limits{MaxSteps: 50}, "let i = 0\nfor {\n\ti = i + 1\n}\n", ErrStepLimit, "step limit exceeded (limit 50)", ""},
+		{Limits{MaxCallDepth: 10}, "func f(n) {\n\treturn f(n + 1)\n}\nf(0)\n", ErrCallDepthLimit, "call depth limit exceeded (limit 10)", ""},
+		{Limits{MaxCallDepth: 3}, "func f(n) {\n\tprint(n)\n\tif n < 4 {\n\t\tf(n + 1)\n\t}\n}\nf(1)\n", ErrCallDepthLimit, "call depth limit exceeded (limit 3)", "1\n2\n3\n"},
+		{Limits{MaxCallDepth: 1}, "func f() {\n\tprint(\"once\")\n\tf()\n}\nf()\n", ErrCallDepthLimit, "call depth limit exceeded (limit 1)", "once\n"},
+		{Limits{MaxStringLen: 5}, "print(\"abc\" + \"de\")\nprint(\"abc\" + \"def\")\n", ErrStringLimit, "string length limit exceeded (limit 5)", "abcde\n"},
+		{Limits{MaxStringLen: 5}, "repeat(\"ab\", 3)\n", ErrStringLimit, "string length limit exceeded (limit 5)", ""},
+		{Limits{MaxStringLen: 5}, "replace(\"aaa\", \"a\", \"bb\")\n", ErrStringLimit, "string length limit exceeded (limit 5)", ""},
+		{Limits{MaxStringLen: 5}, "let x = 123\nprint(\"${x}${x}\")\n", ErrStringLimit, "string length limit exceeded (limit 5)", ""},
+		{Limits{MaxElements: 4}, "let xs = [1, 2]\nappend(xs, 3, 4)\nprint(xs)\nappend(xs, 5)\n", ErrElementLimit, "element limit exceeded (limit 4)", "[1, 2, 3, 4]\n"},
+		{Limits{MaxElements: 2}, "let m = {\"a\": 1}\nm[\"a\"] = 2\nm[\"b\"] = 3\nm[\"c\"] = 4\n", ErrElementLimit, "element limit exceeded (limit 2)", ""},
+		{Limits{MaxElements: 3}, "let xs = [1, 2]\nlet ys = xs[:]\n", ErrElementLimit, "element limit exceeded (limit 3)", ""},
+		{Limits{MaxOutputBytes: 7}, "print(\"abc\")\nprint(\"de\")\nprint(\"f\")\n", ErrOutputLimit, "output limit exceeded (limit 7)", "abc\nde\n"},
+		// nested lists which are exponentially long when formatted
+		{nestedLimits, nested + "print(a)\n", ErrOutputLimit, "output limit exceeded (limit 1000)", ""},
+		{nestedLimits, nested + "let s = \"${a}\"\n", ErrStringLimit, "string length limit exceeded (limit 1000)", ""},
+		{nestedLimits, nested + "let b = [1]\nfor let i = 0; i < 26; i = i + 1 {\n\tb = [b, b]\n}\nprint(a == a, a == b, a != [a, a])\nfor {\n}\n", ErrStepLimit, "step limit exceeded (limit 100000)", "true true true\n"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		in := New(&out)
+		in.SetLimits(test.limits)
+		err := in.Execute(parse(t, test.src))
+		if !errors.Is(err, test.err) {
+			t.Errorf("%q: expected %v, got %v", test.src, test.err, err)
+			continue
+		}
+		if msg := err.(*RuntimeError).Msg; msg != test.msg {
+			t.Errorf("%q: expected %q, got %q", test.src, test.msg, msg)
+		}
+		if out.String() != test.out {
+			t.Errorf("%q: expected output %q, got %q", test.src, test.out, out.String())
+		}
+
+		// the interpreter stays usable with new limits
+		in.SetLimits(Limits{})
+		err = in.Execute(parse(t, "func deep(n) {\n\tif n > 0 {\n\t\tdeep(n - 1)\n\t}\n}\ndeep(500)\n"))
+		if err != nil {
+			t.Errorf("%q: unexpected error after resetting the limits: %v", test.src, err)
+		}
+	}
+}
+
+func TestInputOutput(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	in := NewWithOptions(Options{
+		Stdin:  strings.NewReader("first\r\nsecond\nlast"),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	src := "print(\"start\")\nlet a = input()\nlet b = readline()\nlet c = readline()\neprint(\"read\", a, len(b), c)\nprint(readline() == \"\")\ninput(\"more? \")\n"
+	err := in.Execute(parse(t, src))
+	if err == nil || err.Error() != "test.tik:7:1: unexpected end of input" {
+		t.Errorf("expected end of input, got %v", err)
+	}
+	if stdout.String() != "start\ntrue\nmore? " {
+		t.Errorf("unexpected stdout %q", stdout.String())
+	}
+	if stderr.String() != "read first 7 last\n" {
+		t.Errorf("unexpected stderr %q", stderr.String())
+	}
+
+	// the output is buffered until the end of the execution or flush()
+	var out writeCounter
+	in = NewWithOptions(Options{Stdout: &out})
+	err = in.Execute(parse(t, "print(1)\nprint(2)\nflush()\nprint(3)\n"))
+	if err != nil || out.writes != 2 || out.String() != "1\n2\n3\n" {
+		t.Errorf("unexpected output %q in %d writes, %v", out.String(), out.writes, err)
+	}
+}
+
+// writeCounter is a buffer which counts the calls of Write.
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestExecuteContext(t *testing.T) {
+	ctx, cancel := stdcontext.WithTimeout(stdcontext.Background(), 10*time.Millisecond)
+	defer cancel()
+	in := New(&bytes.Buffer{})
+	err := in.ExecuteContext(ctx, parse(t, "func spin() {\n\tlet i = 0\n\tfor {\n\t\ti = i + 1\n\t}\n}\nspin()\n"))
+	if !errors.Is(err, stdcontext.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	rtErr := err.(*RuntimeError)
+	if rtErr.Pos.Line < 3 || rtErr.Pos.Line > 4 || len(rtErr.Stack) != 2 {
+		t.Errorf("expected error inside the loop, got %s", rtErr.Traceback())
+	}
+
+	// formatting an exponentially long value stops as well
+	ctx, cancel = stdcontext.WithTimeout(stdcontext.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = in.ExecuteContext(ctx, parse(t, "let a = [1]\nfor let i = 0; i < 26; i = i + 1 {\n\ta = [a, a]\n}\nprint(a)\n"))
+	if !errors.Is(err, stdcontext.DeadlineExceeded) || err.(*RuntimeError).Pos.Line != 5 {
+		t.Errorf("expected deadline error while printing, got %v", err)
+	}
+
+	// the interpreter can be used again afterwards
+	var out bytes.Buffer
+	in = New(&out)
+	canceled, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+	err = in.ExecuteContext(canceled, parse(t, "print(1)\n"))
+	if !errors.Is(err, stdcontext.Canceled) || err.Error() != "test.tik:1:1: execution canceled: context canceled" {
+		t.Errorf("expected cancellation before the first statement, got %v", err)
+	}
+	err = in.Execute(parse(t, "print(2)\n"))
+	if err != nil || out.String() != "2\n" {
+		t.Errorf("unexpected result %q, %v", out.String(), err)
+	}
+}
+
+func parse(t *testing.T, src string) ast.Node {
+	t.Helper()
+	par := parser.New(lexer.NewFile("test.tik", strings.NewReader(src)))
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func pos(line, col, offset int) source.Pos {
+	return source.Pos{Filename: "test.tik", Offset: offset, Line: line, Column: col}
+}
@@ -0,0 +1,83 @@
+package interpreter
+
+import (
+	"errors"
+
+	"github.com/pseidemann/tik/ast"
+)
+
+// DefaultMaxCallDepth is the maximum call depth if Limits.MaxCallDepth is not set.
+// Unlimited recursion would crash the Go runtime, so calls are always limited.
+const DefaultMaxCallDepth = 1000
+
+// Limits restricts the resources used by a program, e.g. to run untrusted code.
+// A limit of 0 means no limit. The usage is counted for each call of Execute,
+// ExecuteContext, Eval or Call from the host.
+type Limits struct {
+	MaxSteps       int // number of evaluated statements and expressions
+	MaxCallDepth   int // number of nested function calls, DefaultMaxCallDepth if 0
+	MaxStringLen   int // length of a created string in bytes
+	MaxElements    int // total number of elements added to lists and maps
+	MaxOutputBytes int // number of bytes written to stdout and stderr
+}
+
+// The errors wrapped by the RuntimeError which aborts a program when it exceeds a limit.
+var (
+	ErrStepLimit      = errors.New("step limit exceeded")
+	ErrCallDepthLimit = errors.New("call depth limit exceeded")
+	ErrStringLimit    = errors.New("string length limit exceeded")
+	ErrElementLimit   = errors.New("element limit exceeded")
+	ErrOutputLimit    = errors.New("output limit exceeded")
+)
+
+// SetLimits sets the resource limits for the program.
+func (in *Interpreter) SetLimits(limits Limits) {
+	if limits.MaxCallDepth == 0 {
+		limits.MaxCallDepth = DefaultMaxCallDepth
+	}
+	in.limits = limits
+}
+
+// usage is the amount of resources used by the current execution.
+type usage struct {
+	steps  int
+	elems  int
+	output int
+}
+
+// exceeded aborts the execution at the given node because a limit was exceeded.
+func (in *Interpreter) exceeded(n ast.Node, err error, limit int) {
+	rtErr := in.errorf(n, "%v (limit %d)", err, limit)
+	rtErr.Err = err
+	panic(rtErr)
+}
+
+// addElems counts elements added to lists and maps and checks them against the limit.
+func (in *Interpreter) addElems(n ast.Node, count int) {
+	in.used.elems += count
+	if in.limits.MaxElements > 0 && in.used.elems > in.limits.MaxElements {
+		in.exceeded(n, ErrElementLimit, in.limits.MaxElements)
+	}
+}
+
+// checkStringLen checks the length of a string before it is created.
+func (in *Interpreter) checkStringLen(n ast.Node, length int) {
+	if in.limits.MaxStringLen > 0 && length > in.limits.MaxStringLen {
+		in.exceeded(n, ErrStringLimit, in.limits.MaxStringLen)
+	}
+}
+
+// checkOutput checks bytes which are about to be written against the remaining output budget.
+func (in *Interpreter) checkOutput(n ast.Node, count int) {
+	if in.limits.MaxOutputBytes > 0 && in.used.output+count > in.limits.MaxOutputBytes {
+		in.exceeded(n, ErrOutputLimit, in.limits.MaxOutputBytes)
+	}
+}
+
+// addOutput counts bytes before they are written to stdout or stderr and checks them against the limit.
+func (in *Interpreter) addOutput(n ast.Node, count int) {
+	in.used.output += count
+	if in.limits.MaxOutputBytes > 0 && in.used.output > in.limits.MaxOutputBytes {
+		in.exceeded(n, ErrOutputLimit, in.limits.MaxOutputBytes)
+	}
+}
@@ -0,0 +1,151 @@
+package interpreter
+
+import "github.com/pseidemann/tik/ast"
+
+// Lists are shared by reference, so modifying an element or appending is visible
+// through all variables holding the list. Slicing creates a new list.
+// Indices can be negative to count from the end, so -1 is the last element.
+
+func newList(elems []*variable) *variable {
+	return &variable{varType: TypeList, listVal: elems}
+}
+
+func (in *Interpreter) execList(n *ast.List) *variable {
+	in.addElems(n, len(n.Elems))
+	elems := make([]*variable, len(n.Elems))
+	for i, elem := range n.Elems {
+		elems[i] = in.execOperand(elem)
+	}
+	return newList(elems)
+}
+
+func (in *Interpreter) execIndex(n *ast.Index) *variable {
+	left := in.execOperand(n.Left)
+	switch left.varType {
+	case TypeList:
+		i := in.index(n.Index, len(left.listVal))
+		return left.listVal[i]
+	case TypeString:
+		runes := []rune(left.strVal)
+		i := in.index(n.Index, len(runes))
+		return newString(string(runes[i]))
+	case TypeMap:
+		key := in.execOperand(n.Index)
+		v, ok := left.mapVal.get(in.mapKeyOf(n.Index, key))
+		if !ok {
+			panic(in.errorf(n.Index, "key %s not found in map", key.repr()))
+		}
+		return v
+	default:
+		panic(in.errorf(n, "cannot index %v", left.varType))
+	}
+}
+
+func (in *Interpreter) execSlice(n *ast.Slice) *variable {
+	left := in.execOperand(n.Left)
+	switch left.varType {
+	case TypeList:
+		low, high := in.sliceBounds(n, len(left.listVal))
+		in.addElems(n, high-low)
+		elems := make([]*variable, high-low)
+		copy(elems, left.listVal[low:high])
+		return newList(elems)
+	case TypeString:
+		runes := []rune(left.strVal)
+		low, high := in.sliceBounds(n, len(runes))
+		return newString(string(runes[low:high]))
+	default:
+		panic(in.errorf(n, "cannot slice %v", left.varType))
+	}
+}
+
+// assignIndex sets the element of a list like xs[i] = v or the value of a map like m[k] = v.
+func (in *Interpreter) assignIndex(n *ast.Index, v *variable) {
+	left := in.execOperand(n.Left)
+	switch left.varType {
+	case TypeList:
+		i := in.index(n.Index, len(left.listVal))
+		left.listVal[i] = v
+	case TypeMap:
+		key := in.execOperand(n.Index)
+		k := in.mapKeyOf(n.Index, key)
+		if _, ok := left.mapVal.get(k); !ok {
+			in.addElems(n, 1)
+		}
+		left.mapVal.set(k, key, v)
+	default:
+		panic(in.errorf(n, "cannot assign to index of %v", left.varType))
+	}
+}
+
+// index evaluates an index for a sequence of the given length.
+// A negative index counts from the end.
+func (in *Interpreter) index(n ast.Node, length int) int {
+	v := in.execOperand(n)
+	if v.varType != TypeInt {
+		panic(in.errorf(n, "index must be int, got %v", v.varType))
+	}
+	i := v.intVal
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i >= length {
+		panic(in.errorf(n, "index %d out of range for length %d", v.intVal, length))
+	}
+	return i
+}
+
+// sliceBounds evaluates the bounds of a slice for a sequence of the given length.
+// Omitted bounds default to the start and the end. Negative bounds count from the end.
+// Bounds outside of the sequence are an error instead of being clamped.
+func (in *Interpreter) sliceBounds(n *ast.Slice, length int) (int, int) {
+	bound := func(b ast.Node, def int) (int, string) {
+		if b == nil {
+			return def, ""
+		}
+		v := in.execOperand(b)
+		if v.varType != TypeInt {
+			panic(in.errorf(b, "slice bound must be int, got %v", v.varType))
+		}
+		if v.intVal < 0 {
+			return v.intVal + length, v.String()
+		}
+		return v.intVal, v.String()
+	}
+	low, lowStr := bound(n.Low, 0)
+	high, highStr := bound(n.High, length)
+	if low < 0 || high > length || low > high {
+		panic(in.errorf(n, "slice bounds [%s:%s] out of range for length %d", lowStr, highStr, length))
+	}
+	return low, high
+}
+
+// builtinAppend appends the values to the list and returns the list.
+func builtinAppend(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	if len(args) < 2 {
+		panic(in.errorf(call, "function %q expects at least 2 args, got %d", callName(call), len(args)))
+	}
+	in.checkArgs(call, args[:1], TypeList)
+	for i, arg := range args[1:] {
+		if arg == nil {
+			panic(in.errorf(call.Args[i+1], "argument %d of %q has no value", i+2, callName(call)))
+		}
+	}
+	in.addElems(call, len(args)-1)
+	list := args[0]
+	list.listVal = append(list.listVal, args[1:]...)
+	return list
+}
+
+// builtinPop removes the last element of the list and returns it.
+func builtinPop(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeList)
+	list := args[0]
+	n := len(list.listVal)
+	if n == 0 {
+		panic(in.errorf(call.Args[0], "pop from empty list"))
+	}
+	last := list.listVal[n-1]
+	list.listVal = list.listVal[:n-1]
+	return last
+}
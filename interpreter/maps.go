@@ -0,0 +1,143 @@
+package interpreter
+
+import (
+	"math"
+
+	"github.com/pseidemann/tik/ast"
+)
+
+// Maps are shared by reference like lists. They keep their keys in insertion order,
+// so that printing, keys(m) and iterating are deterministic.
+
+// orderedMap is the value of a map.
+type orderedMap struct {
+	entries []*mapEntry
+	index   map[mapKey]*mapEntry
+}
+
+type mapEntry struct {
+	key   *variable
+	value *variable
+}
+
+// mapKey is the comparable representation of a key.
+// Whole floats are stored like ints, because they are equal to them.
+type mapKey struct {
+	varType  Type
+	intVal   int
+	strVal   string
+	boolVal  bool
+	floatVal float64
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{index: make(map[mapKey]*mapEntry)}
+}
+
+// toMapKey converts a value to a key. It reports false for values which can't be keys.
+func toMapKey(v *variable) (mapKey, bool) {
+	switch v.varType {
+	case TypeInt:
+		return mapKey{varType: TypeInt, intVal: v.intVal}, true
+	case TypeFloat:
+		f := v.floatVal
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return mapKey{varType: TypeInt, intVal: int(f)}, true
+		}
+		return mapKey{varType: TypeFloat, floatVal: f}, true
+	case TypeString:
+		return mapKey{varType: TypeString, strVal: v.strVal}, true
+	case TypeBool:
+		return mapKey{varType: TypeBool, boolVal: v.boolVal}, true
+	default:
+		return mapKey{}, false
+	}
+}
+
+func (m *orderedMap) get(k mapKey) (*variable, bool) {
+	e, ok := m.index[k]
+	if !ok {
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (m *orderedMap) set(k mapKey, key, value *variable) {
+	if e, ok := m.index[k]; ok {
+		e.value = value
+		return
+	}
+	e := &mapEntry{key: key, value: value}
+	m.entries = append(m.entries, e)
+	m.index[k] = e
+}
+
+func (m *orderedMap) delete(k mapKey) {
+	e, ok := m.index[k]
+	if !ok {
+		return
+	}
+	delete(m.index, k)
+	for i, other := range m.entries {
+		if other == e {
+			m.entries = append(m.entries[:i], m.entries[i+1:]...)
+			break
+		}
+	}
+}
+
+// mapKeyOf evaluates a key and makes sure that it can be used in a map.
+func (in *Interpreter) mapKeyOf(n ast.Node, key *variable) mapKey {
+	k, ok := toMapKey(key)
+	if !ok {
+		panic(in.errorf(n, "invalid map key type %v", key.varType))
+	}
+	return k
+}
+
+func (in *Interpreter) execMap(n *ast.Map) *variable {
+	in.addElems(n, len(n.Keys))
+	m := newOrderedMap()
+	for i, keyNode := range n.Keys {
+		key := in.execOperand(keyNode)
+		k := in.mapKeyOf(keyNode, key)
+		m.set(k, key, in.execOperand(n.Values[i]))
+	}
+	return &variable{varType: TypeMap, mapVal: m}
+}
+
+func builtinHas(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkMapArgs(call, args)
+	_, ok := args[0].mapVal.get(in.mapKeyOf(call.Args[1], args[1]))
+	return &variable{varType: TypeBool, boolVal: ok}
+}
+
+// builtinDelete removes a key from a map. Deleting a missing key does nothing.
+func builtinDelete(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkMapArgs(call, args)
+	args[0].mapVal.delete(in.mapKeyOf(call.Args[1], args[1]))
+	return nil
+}
+
+// builtinKeys returns the keys of a map as list in insertion order.
+func builtinKeys(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeMap)
+	entries := args[0].mapVal.entries
+	in.addElems(call, len(entries))
+	keys := make([]*variable, len(entries))
+	for i, e := range entries {
+		keys[i] = e.key
+	}
+	return newList(keys)
+}
+
+// checkMapArgs makes sure that the builtin is called with a map and a key.
+func (in *Interpreter) checkMapArgs(call *ast.FuncCall, args []*variable) {
+	if len(args) != 2 {
+		panic(in.errorf(call, "function %q expects 2 args, got %d", callName(call), len(args)))
+	}
+	if args[1] == nil {
+		panic(in.errorf(call.Args[1], "argument 2 of %q has no value", callName(call)))
+	}
+	in.checkArgs(call, args[:1], TypeMap)
+}
@@ -0,0 +1,132 @@
+package interpreter
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pseidemann/tik/ast"
+)
+
+// String builtins count lengths and indices in characters, not in bytes.
+
+func newString(s string) *variable {
+	return &variable{varType: TypeString, strVal: s}
+}
+
+func builtinLen(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	if len(args) != 1 {
+		panic(in.errorf(call, "function %q expects 1 args, got %d", callName(call), len(args)))
+	}
+	switch arg := args[0]; {
+	case arg == nil:
+		panic(in.errorf(call.Args[0], "argument 1 of %q has no value", callName(call)))
+	case arg.varType == TypeString:
+		return &variable{varType: TypeInt, intVal: utf8.RuneCountInString(arg.strVal)}
+	case arg.varType == TypeList:
+		return &variable{varType: TypeInt, intVal: len(arg.listVal)}
+	case arg.varType == TypeMap:
+		return &variable{varType: TypeInt, intVal: len(arg.mapVal.entries)}
+	default:
+		panic(in.errorf(call.Args[0], "argument 1 of %q must be string, list or map, got %v", callName(call), arg.varType))
+	}
+}
+
+func builtinUpper(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeString)
+	return newString(strings.ToUpper(args[0].strVal))
+}
+
+func builtinLower(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeString)
+	return newString(strings.ToLower(args[0].strVal))
+}
+
+func builtinTrim(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeString)
+	return newString(strings.TrimSpace(args[0].strVal))
+}
+
+// builtinSubstr returns the characters from start up to, but excluding, end.
+func builtinSubstr(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeString, TypeInt, TypeInt)
+	runes := []rune(args[0].strVal)
+	start, end := args[1].intVal, args[2].intVal
+	if start < 0 || start > len(runes) {
+		panic(in.errorf(call.Args[1], "start %d out of range for string of length %d", start, len(runes)))
+	}
+	if end < start || end > len(runes) {
+		panic(in.errorf(call.Args[2], "end %d out of range [%d, %d]", end, start, len(runes)))
+	}
+	return newString(string(runes[start:end]))
+}
+
+// builtinSplit splits a string at each separator into a list.
+// An empty separator splits after each character.
+func builtinSplit(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeString, TypeString)
+	parts := strings.Split(args[0].strVal, args[1].strVal)
+	in.addElems(call, len(parts))
+	list := make([]*variable, len(parts))
+	for i, part := range parts {
+		list[i] = newString(part)
+	}
+	return &variable{varType: TypeList, listVal: list}
+}
+
+func builtinJoin(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeList, TypeString)
+	sep := args[1].strVal
+	parts := make([]string, len(args[0].listVal))
+	length := 0
+	for i, elem := range args[0].listVal {
+		if elem.varType != TypeString {
+			panic(in.errorf(call.Args[0], "element %d of list must be string, got %v", i, elem.varType))
+		}
+		parts[i] = elem.strVal
+		length += len(elem.strVal)
+	}
+	if len(parts) > 0 {
+		length += len(sep) * (len(parts) - 1)
+	}
+	in.checkStringLen(call, length)
+	return newString(strings.Join(parts, sep))
+}
+
+func builtinContains(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeString, TypeString)
+	return &variable{varType: TypeBool, boolVal: strings.Contains(args[0].strVal, args[1].strVal)}
+}
+
+// builtinIndex returns the index of the first occurrence of a substring or -1.
+func builtinIndex(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeString, TypeString)
+	s := args[0].strVal
+	i := strings.Index(s, args[1].strVal)
+	if i > 0 {
+		i = utf8.RuneCountInString(s[:i])
+	}
+	return &variable{varType: TypeInt, intVal: i}
+}
+
+// builtinReplace replaces all occurrences of old by new.
+func builtinReplace(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeString, TypeString, TypeString)
+	s, old, repl := args[0].strVal, args[1].strVal, args[2].strVal
+	in.checkStringLen(call, len(s)+strings.Count(s, old)*(len(repl)-len(old)))
+	return newString(strings.ReplaceAll(s, old, repl))
+}
+
+func builtinRepeat(in *Interpreter, call *ast.FuncCall, args []*variable) *variable {
+	in.checkArgs(call, args, TypeString, TypeInt)
+	n := args[1].intVal
+	if n < 0 {
+		panic(in.errorf(call.Args[1], "negative repeat count %d", n))
+	}
+	s := args[0].strVal
+	if len(s) > 0 && n > math.MaxInt/len(s) {
+		panic(in.errorf(call.Args[1], "repeat count %d too large", n))
+	}
+	in.checkStringLen(call, len(s)*n)
+	return newString(strings.Repeat(s, n))
+}
@@ -0,0 +1,239 @@
+package interpreter
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Value is a tik value which is passed between Go and tik programs.
+// The zero Value stands for no value, like the result of a function without return.
+//
+// Lists and maps are shared by reference, like in tik: changes made by a
+// program are visible to all Values of the same list or map.
+type Value struct {
+	v *variable
+}
+
+// IsValid reports whether v holds a value, as opposed to the zero Value.
+func (v Value) IsValid() bool {
+	return v.v != nil
+}
+
+// Type returns the type of the value. It panics for the zero Value.
+func (v Value) Type() Type {
+	if v.v == nil {
+		panic("interpreter: Type of zero Value")
+	}
+	return v.v.varType
+}
+
+// Int returns the value of an int. It panics if v is not an int.
+func (v Value) Int() int {
+	v.mustBe(TypeInt, "Int")
+	return v.v.intVal
+}
+
+// Float returns the value of a number as float. It panics if v is not an int or float.
+func (v Value) Float() float64 {
+	if v.v == nil || !v.v.isNumber() {
+		panic(fmt.Sprintf("interpreter: Float of %s value", v.typeName()))
+	}
+	return v.v.toFloat()
+}
+
+// Bool returns the value of a bool. It panics if v is not a bool.
+func (v Value) Bool() bool {
+	v.mustBe(TypeBool, "Bool")
+	return v.v.boolVal
+}
+
+// String returns the value as it is printed by print, so for strings
+// it is the string itself. The zero Value returns "<no value>".
+func (v Value) String() string {
+	if v.v == nil {
+		return "<no value>"
+	}
+	return v.v.String()
+}
+
+// List returns the elements of a list. It panics if v is not a list.
+// The returned slice is a copy, but the elements are shared with the list.
+func (v Value) List() []Value {
+	v.mustBe(TypeList, "List")
+	elems := make([]Value, len(v.v.listVal))
+	for i, elem := range v.v.listVal {
+		elems[i] = Value{elem}
+	}
+	return elems
+}
+
+// Interface converts the value to a Go value: int, float64, string, bool,
+// []interface{} for lists and map[interface{}]interface{} for maps.
+// Functions are returned as Value and the zero Value as nil.
+// A list or map which contains itself results in a slice or map which contains itself.
+func (v Value) Interface() interface{} {
+	if v.v == nil {
+		return nil
+	}
+	return toInterface(v.v, make(map[*variable]interface{}))
+}
+
+// toInterface implements Interface. The lists and maps which are converted already
+// are kept in converted, so that they are converted only once.
+func toInterface(v *variable, converted map[*variable]interface{}) interface{} {
+	switch v.varType {
+	case TypeInt:
+		return v.intVal
+	case TypeFloat:
+		return v.floatVal
+	case TypeString:
+		return v.strVal
+	case TypeBool:
+		return v.boolVal
+	case TypeList:
+		if c, ok := converted[v]; ok {
+			return c
+		}
+		elems := make([]interface{}, len(v.listVal))
+		converted[v] = elems
+		for i, elem := range v.listVal {
+			elems[i] = toInterface(elem, converted)
+		}
+		return elems
+	case TypeMap:
+		if c, ok := converted[v]; ok {
+			return c
+		}
+		m := make(map[interface{}]interface{}, len(v.mapVal.entries))
+		converted[v] = m
+		for _, e := range v.mapVal.entries {
+			m[toInterface(e.key, converted)] = toInterface(e.value, converted)
+		}
+		return m
+	default:
+		return Value{v}
+	}
+}
+
+func (v Value) mustBe(t Type, method string) {
+	if v.v == nil || v.v.varType != t {
+		panic(fmt.Sprintf("interpreter: %s of %s value", method, v.typeName()))
+	}
+}
+
+func (v Value) typeName() string {
+	if v.v == nil {
+		return "zero"
+	}
+	return v.v.varType.String()
+}
+
+// Int creates an int value.
+func Int(i int) Value {
+	return Value{&variable{varType: TypeInt, intVal: i}}
+}
+
+// Float creates a float value.
+func Float(f float64) Value {
+	return Value{&variable{varType: TypeFloat, floatVal: f}}
+}
+
+// String creates a string value.
+func String(s string) Value {
+	return Value{newString(s)}
+}
+
+// Bool creates a bool value.
+func Bool(b bool) Value {
+	return Value{&variable{varType: TypeBool, boolVal: b}}
+}
+
+// List creates a list of the given elements.
+func List(elems ...Value) Value {
+	vars := make([]*variable, len(elems))
+	for i, elem := range elems {
+		if !elem.IsValid() {
+			panic("interpreter: List with zero Value")
+		}
+		vars[i] = elem.v
+	}
+	return Value{newList(vars)}
+}
+
+// ValueOf converts a Go value to a tik value. Supported are the integer and
+// float types, string, bool, Value and slices, arrays and maps of supported types.
+// Map keys must convert to int, float, string or bool.
+func ValueOf(x interface{}) (Value, error) {
+	if x == nil {
+		return Value{}, fmt.Errorf("cannot convert nil to a tik value")
+	}
+	return valueOf(reflect.ValueOf(x))
+}
+
+func valueOf(rv reflect.Value) (Value, error) {
+	if v, ok := rv.Interface().(Value); ok {
+		if !v.IsValid() {
+			return Value{}, fmt.Errorf("cannot convert the zero Value")
+		}
+		return v, nil
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int(int(rv.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Int(int(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return Float(rv.Float()), nil
+	case reflect.String:
+		return String(rv.String()), nil
+	case reflect.Bool:
+		return Bool(rv.Bool()), nil
+	case reflect.Interface:
+		if rv.IsNil() {
+			return Value{}, fmt.Errorf("cannot convert nil to a tik value")
+		}
+		return valueOf(rv.Elem())
+	case reflect.Slice, reflect.Array:
+		elems := make([]*variable, rv.Len())
+		for i := range elems {
+			elem, err := valueOf(rv.Index(i))
+			if err != nil {
+				return Value{}, err
+			}
+			elems[i] = elem.v
+		}
+		return Value{newList(elems)}, nil
+	case reflect.Map:
+		// Go maps are unordered, so the keys are sorted to get a deterministic order
+		keys := make([]*variable, 0, rv.Len())
+		values := make(map[*variable]*variable, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := valueOf(iter.Key())
+			if err != nil {
+				return Value{}, err
+			}
+			value, err := valueOf(iter.Value())
+			if err != nil {
+				return Value{}, err
+			}
+			keys = append(keys, key.v)
+			values[key.v] = value.v
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].repr() < keys[j].repr()
+		})
+		m := newOrderedMap()
+		for _, key := range keys {
+			k, ok := toMapKey(key)
+			if !ok {
+				return Value{}, fmt.Errorf("invalid map key type %v", key.varType)
+			}
+			m.set(k, key, values[key])
+		}
+		return Value{&variable{varType: TypeMap, mapVal: m}}, nil
+	default:
+		return Value{}, fmt.Errorf("cannot convert %v to a tik value", rv.Type())
+	}
+}
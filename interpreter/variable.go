@@ -0,0 +1,251 @@
+package interpreter
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of a tik value.
+type Type int
+
+// All available types.
+const (
+	TypeInt Type = iota
+	TypeFloat
+	TypeString
+	TypeBool
+	TypeList
+	TypeMap
+	TypeFunc
+
+	// TypeAny is only used for the parameters of registered functions,
+	// where it accepts arguments of any type.
+	TypeAny
+)
+
+var typeNames = [...]string{
+	"int",
+	"float",
+	"string",
+	"bool",
+	"list",
+	"map",
+	"func",
+	"any",
+}
+
+func (t Type) String() string {
+	return typeNames[t]
+}
+
+type variable struct {
+	varType  Type
+	intVal   int
+	floatVal float64
+	strVal   string
+	boolVal  bool
+	listVal  []*variable
+	mapVal   *orderedMap
+	funcVal  *function
+}
+
+func (v *variable) isNumber() bool {
+	return v.varType == TypeInt || v.varType == TypeFloat
+}
+
+// toFloat returns the value of a number as float.
+func (v *variable) toFloat() float64 {
+	if v.varType == TypeInt {
+		return float64(v.intVal)
+	}
+	return v.floatVal
+}
+
+// String returns the value as it is printed by print.
+func (v *variable) String() string {
+	return v.format(false)
+}
+
+// format returns the value as string, quoting strings if quote is set.
+func (v *variable) format(quote bool) string {
+	var f formatter
+	f.format(v, quote)
+	return f.b.String()
+}
+
+// formatter formats values into a string. Nested lists and maps can make the result
+// exponentially long, so visit, if set, is called before each value with the length of
+// the result so far. It can stop the formatting by panicking.
+type formatter struct {
+	b     strings.Builder
+	visit func(length int)
+	// Lists and maps can contain themselves, so visiting holds the lists and maps which are
+	// being formatted. When one of them is reached again, it is shown as [...] or {...}.
+	visiting map[*variable]bool
+}
+
+// format writes the value, quoting strings if quote is set.
+func (f *formatter) format(v *variable, quote bool) {
+	if f.visit != nil {
+		f.visit(f.b.Len())
+	}
+	switch v.varType {
+	case TypeInt:
+		f.b.WriteString(strconv.Itoa(v.intVal))
+	case TypeFloat:
+		f.b.WriteString(formatFloat(v.floatVal))
+	case TypeString:
+		if quote {
+			f.b.WriteString(strconv.Quote(v.strVal))
+		} else {
+			f.b.WriteString(v.strVal)
+		}
+	case TypeBool:
+		f.b.WriteString(strconv.FormatBool(v.boolVal))
+	case TypeList:
+		if f.visiting[v] {
+			f.b.WriteString("[...]")
+			return
+		}
+		f.enter(v)
+		defer delete(f.visiting, v)
+		f.b.WriteByte('[')
+		for i, elem := range v.listVal {
+			if i > 0 {
+				f.b.WriteString(", ")
+			}
+			f.format(elem, true)
+		}
+		f.b.WriteByte(']')
+	case TypeMap:
+		if f.visiting[v] {
+			f.b.WriteString("{...}")
+			return
+		}
+		f.enter(v)
+		defer delete(f.visiting, v)
+		f.b.WriteByte('{')
+		for i, e := range v.mapVal.entries {
+			if i > 0 {
+				f.b.WriteString(", ")
+			}
+			f.format(e.key, true)
+			f.b.WriteString(": ")
+			f.format(e.value, true)
+		}
+		f.b.WriteByte('}')
+	case TypeFunc:
+		f.b.WriteString(v.funcVal.String())
+	default:
+		f.b.WriteString("<unknown>")
+	}
+}
+
+// enter marks a list or map as being formatted.
+func (f *formatter) enter(v *variable) {
+	if f.visiting == nil {
+		f.visiting = make(map[*variable]bool)
+	}
+	f.visiting[v] = true
+}
+
+// formatFloat formats a float with the least digits needed to represent it exactly.
+// To distinguish them from ints, whole numbers keep a ".0". Very large and very small
+// numbers use exponent notation.
+func formatFloat(f float64) string {
+	abs := math.Abs(f)
+	if math.IsInf(f, 0) || math.IsNaN(f) || abs != 0 && (abs < 1e-4 || abs >= 1e21) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// repr returns the value as it is written in source code.
+func (v *variable) repr() string {
+	return v.format(true)
+}
+
+// comparison compares values. Nested lists and maps can be compared many times, so
+// visit, if set, is called for each compared list or map. It can stop the comparison by panicking.
+type comparison struct {
+	visit func()
+	// Lists and maps can contain themselves, so equal holds the pairs of lists and maps
+	// which are being compared or were found equal. When a pair is reached again, it is
+	// considered equal, because a difference fails the whole comparison anyway. This also
+	// compares a pair only once if it is contained many times.
+	equal map[[2]*variable]bool
+}
+
+// equals reports whether both values are of the same type and equal.
+// Ints and floats are compared by their numeric value.
+func (c *comparison) equals(v, other *variable) bool {
+	if v.isNumber() && other.isNumber() && v.varType != other.varType {
+		return v.toFloat() == other.toFloat()
+	}
+	if v.varType != other.varType {
+		return false
+	}
+	switch v.varType {
+	case TypeInt:
+		return v.intVal == other.intVal
+	case TypeFloat:
+		return v.floatVal == other.floatVal
+	case TypeString:
+		return v.strVal == other.strVal
+	case TypeBool:
+		return v.boolVal == other.boolVal
+	case TypeList:
+		if len(v.listVal) != len(other.listVal) {
+			return false
+		}
+		if v == other || !c.enter(v, other) {
+			return true
+		}
+		for i, elem := range v.listVal {
+			if !c.equals(elem, other.listVal[i]) {
+				return false
+			}
+		}
+		return true
+	case TypeMap:
+		if len(v.mapVal.entries) != len(other.mapVal.entries) {
+			return false
+		}
+		if v == other || !c.enter(v, other) {
+			return true
+		}
+		for k, e := range v.mapVal.index {
+			value, ok := other.mapVal.get(k)
+			if !ok || !c.equals(e.value, value) {
+				return false
+			}
+		}
+		return true
+	case TypeFunc:
+		return v.funcVal == other.funcVal
+	default:
+		return false
+	}
+}
+
+// enter marks a pair of lists or maps as being compared. It reports false if the pair
+// was reached before.
+func (c *comparison) enter(v, other *variable) bool {
+	pair := [2]*variable{v, other}
+	if c.equal[pair] {
+		return false
+	}
+	if c.visit != nil {
+		c.visit()
+	}
+	if c.equal == nil {
+		c.equal = make(map[[2]*variable]bool)
+	}
+	c.equal[pair] = true
+	return true
+}
@@ -7,26 +7,79 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pseidemann/tik/source"
 )
 
 // ErrEOF is the error which is returned when all tokens are consumed.
 var ErrEOF = errors.New("no more tokens")
 
+// Error is returned for source code which can't be split into tokens.
+type Error struct {
+	Pos source.Pos
+	Msg string
+	EOF bool // whether the error is caused by the input ending too early
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v: %s", e.Pos, e.Msg)
+}
+
 // Lexer can parse source code into a sequence of tokens.
 type Lexer struct {
-	buf *bufio.Reader
+	buf              *bufio.Reader
+	pos              source.Pos // position of the next rune
+	prevPos          source.Pos // position before the last read rune
+	preserveComments bool
+	interpolations   []int // open braces of each unfinished interpolation in a string
 }
 
 // New creates a Lexer.
 func New(rd io.Reader) *Lexer {
+	return NewFile("", rd)
+}
+
+// NewFile creates a Lexer which reports positions for the given file name.
+func NewFile(filename string, rd io.Reader) *Lexer {
 	return &Lexer{
 		buf: bufio.NewReader(rd),
+		pos: source.Pos{Filename: filename, Line: 1, Column: 1},
 	}
 }
 
+// PreserveComments sets whether comments are returned as tokens of type TypeComment.
+// By default, comments are skipped like whitespace.
+func (l *Lexer) PreserveComments(preserve bool) {
+	l.preserveComments = preserve
+}
+
+// Pos returns the position of the next character to be read.
+// After ErrEOF, it is the position of the end of the input.
+func (l *Lexer) Pos() source.Pos {
+	return l.pos
+}
+
 // NextToken returns the next token available.
 func (l *Lexer) NextToken() (*Token, error) {
-	r, _, err := l.buf.ReadRune()
+	start := l.pos
+	tok, err := l.scan()
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		// skipped whitespace or comment
+		return l.NextToken()
+	}
+	tok.Pos = start
+	tok.End = l.pos
+	return tok, nil
+}
+
+func (l *Lexer) scan() (*Token, error) {
+	r, err := l.readRune()
 	if err != nil {
 		if err == io.EOF {
 			// wrap with our own error to encapsulate implementation details
@@ -34,17 +87,26 @@ func (l *Lexer) NextToken() (*Token, error) {
 		}
 		return nil, err
 	}
+	// reading ahead, e.g. for operators, moves prevPos, so the start is kept
+	start := l.prevPos
 
 	if isDigit(r) {
 		l.unreadRune()
-		num, err := l.readWhile(isDigit)
+		num, err := l.readNumber()
 		if err != nil {
 			return nil, err
 		}
 		return &Token{TokenType: TypeNum, Value: num}, nil
-	} else if isOp(r) {
-		return &Token{TokenType: TypeOp, Value: string(r), Precedence: opPrecedence[r]}, nil
-	} else if isIdent(r) {
+	} else if r == '/' && l.atComment() {
+		return l.scanComment()
+	} else if isOpStart(r) {
+		op, ok := l.readOp(r)
+		if ok {
+			return &Token{TokenType: TypeOp, Value: op, Precedence: opPrecedence[op]}, nil
+		}
+	}
+
+	if isIdent(r) {
 		l.unreadRune()
 		ident, err := l.readWhile(isIdent)
 		if err != nil {
@@ -65,13 +127,18 @@ func (l *Lexer) NextToken() (*Token, error) {
 		}
 		return &Token{TokenType: TypeParenR, Precedence: 100}, nil
 	} else if r == '{' {
-		if err != nil {
-			return nil, err
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1]++
 		}
 		return &Token{TokenType: TypeBraceL}, nil
 	} else if r == '}' {
-		if err != nil {
-			return nil, err
+		if n := len(l.interpolations); n > 0 {
+			if l.interpolations[n-1] == 0 {
+				// end of the embedded expression, continue with the string
+				l.interpolations = l.interpolations[:n-1]
+				return l.scanString(TypeStringMid, TypeStringEnd)
+			}
+			l.interpolations[n-1]--
 		}
 		return &Token{TokenType: TypeBraceR}, nil
 	} else if r == '\n' {
@@ -79,20 +146,23 @@ func (l *Lexer) NextToken() (*Token, error) {
 			return nil, err
 		}
 		return &Token{TokenType: TypeNewline, Precedence: 1000}, nil
+	} else if r == '[' {
+		return &Token{TokenType: TypeBracketL, Precedence: 100}, nil
+	} else if r == ']' {
+		return &Token{TokenType: TypeBracketR, Precedence: 100}, nil
+	} else if r == ':' {
+		return &Token{TokenType: TypeColon}, nil
 	} else if r == ',' {
 		if err != nil {
 			return nil, err
 		}
 		return &Token{TokenType: TypeComma}, nil
+	} else if r == ';' {
+		return &Token{TokenType: TypeSemicolon}, nil
 	} else if r == '"' {
-		if err != nil {
-			return nil, err
-		}
-		str, err := l.readWhile(insideString)
-		if err != nil {
-			return nil, err
-		}
-		_, _, err = l.buf.ReadRune() // discard closing "
+		return l.scanString(TypeStringStart, TypeString)
+	} else if r == '`' {
+		str, err := l.readRawString()
 		if err != nil {
 			return nil, err
 		}
@@ -104,10 +174,291 @@ func (l *Lexer) NextToken() (*Token, error) {
 		if err != nil {
 			return nil, err
 		}
-		return l.NextToken()
+		return nil, nil
+	}
+
+	return nil, &Error{Pos: start, Msg: fmt.Sprintf("invalid rune found %#v", string(r))}
+}
+
+// atComment reports whether a '/' just read starts a comment.
+// Peeking prevents unreading the '/', so it must only be called for a '/'.
+func (l *Lexer) atComment() bool {
+	b := l.peek(1)
+	return len(b) == 1 && (b[0] == '/' || b[0] == '*')
+}
+
+// scanComment scans a comment after its leading '/'.
+// Line comments run until the end of the line, excluding the newline.
+// Block comments can be nested, so that code containing comments can be commented out.
+// If comments aren't preserved, a block comment spanning multiple lines is returned
+// as a newline, so it separates statements like a line break.
+func (l *Lexer) scanComment() (*Token, error) {
+	start := l.prevPos
+	r, _ := l.readRune()
+	var text string
+	if r == '/' {
+		body, err := l.readWhile(func(r rune) bool { return r != '\n' })
+		if err != nil {
+			return nil, err
+		}
+		text = "//" + body
+	} else {
+		var b bytes.Buffer
+		b.WriteString("/*")
+		depth := 1
+		for depth > 0 {
+			r, err := l.readRune()
+			if err == io.EOF {
+				return nil, &Error{Pos: start, Msg: "unterminated block comment", EOF: true}
+			}
+			if err != nil {
+				return nil, err
+			}
+			b.WriteRune(r)
+			next := l.peek(1)
+			if len(next) == 0 {
+				continue
+			}
+			if r == '/' && next[0] == '*' {
+				depth++
+			} else if r == '*' && next[0] == '/' {
+				depth--
+			} else {
+				continue
+			}
+			l.readRune()
+			b.WriteByte(next[0])
+		}
+		text = b.String()
+	}
+
+	if l.preserveComments {
+		return &Token{TokenType: TypeComment, Value: text}, nil
+	}
+	if strings.ContainsRune(text, '\n') {
+		return &Token{TokenType: TypeNewline, Precedence: 1000}, nil
+	}
+	return nil, nil
+}
+
+// scanString scans a string literal after its opening '"' or after the '}' of an embedded expression.
+// If the literal ends, the token is of type end. If an embedded expression "${" starts,
+// the token is of type open and the following tokens belong to the expression until its closing '}'.
+func (l *Lexer) scanString(open, end TokenType) (*Token, error) {
+	str, interpolated, err := l.readString()
+	if err != nil {
+		return nil, err
+	}
+	if interpolated {
+		l.interpolations = append(l.interpolations, 0)
+		return &Token{TokenType: open, Value: str}, nil
+	}
+	return &Token{TokenType: end, Value: str}, nil
+}
+
+// readString reads a string literal and resolves the escape sequences.
+// It stops at the closing '"' or, reporting true, after the "${" of an embedded expression.
+// Like in Go, the literal must end on the same line.
+func (l *Lexer) readString() (string, bool, error) {
+	start := l.prevPos
+	var b strings.Builder
+	for {
+		r, err := l.readRune()
+		if err == io.EOF {
+			return "", false, &Error{Pos: start, Msg: "unterminated string literal", EOF: true}
+		}
+		if err != nil {
+			return "", false, err
+		}
+		switch r {
+		case '"':
+			return b.String(), false, nil
+		case '$':
+			if next := l.peek(1); len(next) == 1 && next[0] == '{' {
+				l.readRune()
+				return b.String(), true, nil
+			}
+			b.WriteRune(r)
+		case '\n':
+			return "", false, &Error{Pos: start, Msg: "unterminated string literal"}
+		case '\\':
+			esc, err := l.readEscape()
+			if err != nil {
+				if lexErr, ok := err.(*Error); ok && !lexErr.EOF {
+					// continue after the literal, so that its rest isn't reported as errors
+					l.skipString()
+				}
+				return "", false, err
+			}
+			b.WriteRune(esc)
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// skipString skips the rest of a string literal up to its closing '"' or the end of the line.
+func (l *Lexer) skipString() {
+	for {
+		r, err := l.readRune()
+		if err != nil || r == '"' {
+			return
+		}
+		if r == '\n' {
+			l.unreadRune()
+			return
+		}
+		if r == '\\' {
+			// an escaped quote doesn't close the literal
+			if next := l.peek(1); len(next) == 1 && next[0] != '\n' {
+				l.readRune()
+			}
+		}
+	}
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'"':  '"',
+	'$':  '$',
+	'\\': '\\',
+}
+
+// readEscape reads an escape sequence after its '\\'.
+// Besides the single-character escapes, \u{...} denotes a Unicode code point in hex.
+func (l *Lexer) readEscape() (rune, error) {
+	start := l.prevPos
+	r, err := l.readRune()
+	if err == io.EOF {
+		return 0, &Error{Pos: start, Msg: "unterminated string literal", EOF: true}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if esc, ok := escapes[r]; ok {
+		return esc, nil
+	}
+	if r != 'u' {
+		if r == '\n' {
+			l.unreadRune()
+		}
+		return 0, &Error{Pos: start, Msg: fmt.Sprintf("unknown escape sequence %#v", "\\"+string(r))}
 	}
 
-	return nil, fmt.Errorf("invalid rune found %#v", string(r))
+	invalid := &Error{Pos: start, Msg: "invalid unicode escape, expected \\u{hex}"}
+	if b := l.peek(1); len(b) == 0 || b[0] != '{' {
+		return 0, invalid
+	}
+	l.readRune()
+	hex, err := l.readWhile(isHexDigit)
+	if err != nil {
+		return 0, err
+	}
+	if b := l.peek(1); hex == "" || len(hex) > 6 || len(b) == 0 || b[0] != '}' {
+		return 0, invalid
+	}
+	l.readRune()
+	code, _ := strconv.ParseUint(hex, 16, 32)
+	if !utf8.ValidRune(rune(code)) {
+		return 0, &Error{Pos: start, Msg: fmt.Sprintf("invalid unicode code point %s", hex)}
+	}
+	return rune(code), nil
+}
+
+// readRawString reads a raw string literal after its opening '`'.
+// Raw strings can span multiple lines and have no escape sequences.
+func (l *Lexer) readRawString() (string, error) {
+	start := l.prevPos
+	str, err := l.readWhile(func(r rune) bool { return r != '`' })
+	if err != nil {
+		return "", err
+	}
+	if _, err := l.readRune(); err != nil { // discard closing `
+		if err == io.EOF {
+			return "", &Error{Pos: start, Msg: "unterminated string literal", EOF: true}
+		}
+		return "", err
+	}
+	return str, nil
+}
+
+// readNumber reads an integer or floating-point number like 12, 1.5 or 2.5e-3.
+func (l *Lexer) readNumber() (string, error) {
+	num, err := l.readWhile(isDigit)
+	if err != nil {
+		return "", err
+	}
+
+	// fraction, only if a digit follows the dot
+	if b := l.peek(2); len(b) == 2 && b[0] == '.' && isDigit(rune(b[1])) {
+		l.readRune()
+		frac, err := l.readWhile(isDigit)
+		if err != nil {
+			return "", err
+		}
+		num += "." + frac
+	}
+
+	// exponent, only if a digit follows the e and the optional sign
+	b := l.peek(3)
+	if len(b) >= 2 && (b[0] == 'e' || b[0] == 'E') {
+		signed := b[1] == '+' || b[1] == '-'
+		if isDigit(rune(b[1])) || signed && len(b) == 3 && isDigit(rune(b[2])) {
+			l.readRune()
+			num += string(b[0])
+			if signed {
+				l.readRune()
+				num += string(b[1])
+			}
+			exp, err := l.readWhile(isDigit)
+			if err != nil {
+				return "", err
+			}
+			num += exp
+		}
+	}
+
+	return num, nil
+}
+
+// peek returns up to n bytes without consuming them.
+func (l *Lexer) peek(n int) []byte {
+	b, _ := l.buf.Peek(n)
+	return b
+}
+
+// readOp reads the operator starting with r, preferring two-character operators.
+// It reports false if r doesn't form an operator, e.g. for a single '='.
+func (l *Lexer) readOp(r rune) (string, bool) {
+	next, err := l.readRune()
+	if err == nil {
+		if op := string(r) + string(next); isOp(op) {
+			return op, true
+		}
+		l.unreadRune()
+	}
+	if op := string(r); isOp(op) {
+		return op, true
+	}
+	return "", false
+}
+
+func (l *Lexer) readRune() (rune, error) {
+	r, size, err := l.buf.ReadRune()
+	if err != nil {
+		return r, err
+	}
+	l.prevPos = l.pos
+	l.pos.Offset += size
+	if r == '\n' {
+		l.pos.Line++
+		l.pos.Column = 1
+	} else {
+		l.pos.Column++
+	}
+	return r, nil
 }
 
 func (l *Lexer) unreadRune() {
@@ -116,14 +467,18 @@ func (l *Lexer) unreadRune() {
 		// should never happen
 		panic(err)
 	}
+	l.pos = l.prevPos
 }
 
 func (l *Lexer) readWhile(predicate func(rune) bool) (string, error) {
 	var b bytes.Buffer
 
 	for {
-		r, _, err := l.buf.ReadRune()
+		r, err := l.readRune()
 		if err != nil {
+			if err == io.EOF {
+				break
+			}
 			return "", err
 		}
 		if !predicate(r) {
@@ -3,7 +3,10 @@ package lexer
 import (
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/pseidemann/tik/source"
 )
 
 func TestFuncSimple(t *testing.T) {
@@ -23,7 +26,7 @@ func TestFuncSimple(t *testing.T) {
 			}
 			break
 		}
-		out = append(out, tok)
+		out = append(out, withoutPos(tok))
 	}
 
 	expected := []*Token{
@@ -33,7 +36,7 @@ func TestFuncSimple(t *testing.T) {
 		{TokenType: TypeParenR, Precedence: 100},
 		{TokenType: TypeBraceL},
 		{TokenType: TypeNewline, Precedence: 1000},
-		{TokenType: TypeKeyword, Precedence: 10, Value: "print"},
+		{TokenType: TypeIdent, Value: "print"},
 		{TokenType: TypeParenL, Precedence: 100},
 		{TokenType: TypeString, Value: "Hello, world!"},
 		{TokenType: TypeParenR, Precedence: 100},
@@ -69,21 +72,21 @@ func TestMath(t *testing.T) {
 			}
 			break
 		}
-		out = append(out, tok)
+		out = append(out, withoutPos(tok))
 	}
 
 	expected := []*Token{
-		{TokenType: TypeKeyword, Precedence: 10, Value: "print"},
+		{TokenType: TypeIdent, Value: "print"},
 		{TokenType: TypeParenL, Precedence: 100},
 		{TokenType: TypeNum, Value: "10"},
-		{TokenType: TypeOp, Precedence: 1, Value: "+"},
+		{TokenType: TypeOp, Precedence: 4, Value: "+"},
 		{TokenType: TypeNum, Value: "2"},
-		{TokenType: TypeOp, Precedence: 2, Value: "*"},
+		{TokenType: TypeOp, Precedence: 5, Value: "*"},
 		{TokenType: TypeParenL, Precedence: 100},
 		{TokenType: TypeNum, Value: "31"},
-		{TokenType: TypeOp, Precedence: 1, Value: "-"},
+		{TokenType: TypeOp, Precedence: 4, Value: "-"},
 		{TokenType: TypeNum, Value: "2"},
-		{TokenType: TypeOp, Precedence: 2, Value: "/"},
+		{TokenType: TypeOp, Precedence: 5, Value: "/"},
 		{TokenType: TypeNum, Value: "2"},
 		{TokenType: TypeParenR, Precedence: 100},
 		{TokenType: TypeParenR, Precedence: 100},
@@ -112,30 +115,30 @@ func TestPrint(t *testing.T) {
 			}
 			break
 		}
-		out = append(out, tok)
+		out = append(out, withoutPos(tok))
 	}
 
 	expected := []*Token{
-		{TokenType: TypeKeyword, Precedence: 10, Value: "print"},
+		{TokenType: TypeIdent, Value: "print"},
 		{TokenType: TypeParenL, Precedence: 100},
 		{TokenType: TypeString, Value: "hello"},
 		{TokenType: TypeParenR, Precedence: 100},
 		{TokenType: TypeNewline, Precedence: 1000},
-		{TokenType: TypeKeyword, Precedence: 10, Value: "print"},
+		{TokenType: TypeIdent, Value: "print"},
 		{TokenType: TypeParenL, Precedence: 100},
 		{TokenType: TypeString, Value: "world1"},
 		{TokenType: TypeComma},
 		{TokenType: TypeString, Value: "world2"},
 		{TokenType: TypeParenR, Precedence: 100},
 		{TokenType: TypeNewline, Precedence: 1000},
-		{TokenType: TypeKeyword, Precedence: 10, Value: "print"},
+		{TokenType: TypeIdent, Value: "print"},
 		{TokenType: TypeParenL, Precedence: 100},
 		{TokenType: TypeString, Value: "world3"},
 		{TokenType: TypeComma},
 		{TokenType: TypeNum, Value: "1"},
-		{TokenType: TypeOp, Precedence: 1, Value: "+"},
+		{TokenType: TypeOp, Precedence: 4, Value: "+"},
 		{TokenType: TypeNum, Value: "2"},
-		{TokenType: TypeOp, Precedence: 2, Value: "*"},
+		{TokenType: TypeOp, Precedence: 5, Value: "*"},
 		{TokenType: TypeNum, Value: "3"},
 		{TokenType: TypeParenR, Precedence: 100},
 		{TokenType: TypeNewline, Precedence: 1000},
@@ -163,29 +166,32 @@ func TestVariables(t *testing.T) {
 			}
 			break
 		}
-		out = append(out, tok)
+		out = append(out, withoutPos(tok))
 	}
 
 	expected := []*Token{
+		{TokenType: TypeKeyword, Precedence: 10, Value: "let"},
 		{TokenType: TypeIdent, Value: "vara"},
 		{TokenType: TypeAssign, Precedence: 100},
 		{TokenType: TypeNum, Value: "1"},
-		{TokenType: TypeOp, Precedence: 1, Value: "+"},
+		{TokenType: TypeOp, Precedence: 4, Value: "+"},
 		{TokenType: TypeNum, Value: "2"},
 		{TokenType: TypeNewline, Precedence: 1000},
+		{TokenType: TypeKeyword, Precedence: 10, Value: "let"},
 		{TokenType: TypeIdent, Value: "varb"},
 		{TokenType: TypeAssign, Precedence: 100},
 		{TokenType: TypeNum, Value: "2"},
-		{TokenType: TypeOp, Precedence: 2, Value: "*"},
+		{TokenType: TypeOp, Precedence: 5, Value: "*"},
 		{TokenType: TypeNum, Value: "4"},
 		{TokenType: TypeNewline, Precedence: 1000},
+		{TokenType: TypeKeyword, Precedence: 10, Value: "const"},
 		{TokenType: TypeIdent, Value: "varc"},
 		{TokenType: TypeAssign, Precedence: 100},
 		{TokenType: TypeIdent, Value: "vara"},
-		{TokenType: TypeOp, Precedence: 1, Value: "+"},
+		{TokenType: TypeOp, Precedence: 4, Value: "+"},
 		{TokenType: TypeIdent, Value: "varb"},
 		{TokenType: TypeNewline, Precedence: 1000},
-		{TokenType: TypeKeyword, Precedence: 10, Value: "print"},
+		{TokenType: TypeIdent, Value: "print"},
 		{TokenType: TypeParenL, Precedence: 100},
 		{TokenType: TypeIdent, Value: "vara"},
 		{TokenType: TypeComma},
@@ -200,3 +206,285 @@ func TestVariables(t *testing.T) {
 		t.Error("unexpected token output")
 	}
 }
+
+func TestOperators(t *testing.T) {
+	lex := New(strings.NewReader("a<=b == !c&&d||e != -1 > 2 >= 3 < true % x[1:]"))
+
+	var out []*Token
+
+	for {
+		tok, err := lex.NextToken()
+		if err != nil {
+			if err != ErrEOF {
+				t.Error("expected EOF error")
+			}
+			break
+		}
+		out = append(out, withoutPos(tok))
+	}
+
+	expected := []*Token{
+		{TokenType: TypeIdent, Value: "a"},
+		{TokenType: TypeOp, Precedence: 3, Value: "<="},
+		{TokenType: TypeIdent, Value: "b"},
+		{TokenType: TypeOp, Precedence: 3, Value: "=="},
+		{TokenType: TypeOp, Precedence: 6, Value: "!"},
+		{TokenType: TypeIdent, Value: "c"},
+		{TokenType: TypeOp, Precedence: 2, Value: "&&"},
+		{TokenType: TypeIdent, Value: "d"},
+		{TokenType: TypeOp, Precedence: 1, Value: "||"},
+		{TokenType: TypeIdent, Value: "e"},
+		{TokenType: TypeOp, Precedence: 3, Value: "!="},
+		{TokenType: TypeOp, Precedence: 4, Value: "-"},
+		{TokenType: TypeNum, Value: "1"},
+		{TokenType: TypeOp, Precedence: 3, Value: ">"},
+		{TokenType: TypeNum, Value: "2"},
+		{TokenType: TypeOp, Precedence: 3, Value: ">="},
+		{TokenType: TypeNum, Value: "3"},
+		{TokenType: TypeOp, Precedence: 3, Value: "<"},
+		{TokenType: TypeKeyword, Precedence: 10, Value: "true"},
+		{TokenType: TypeOp, Precedence: 5, Value: "%"},
+		{TokenType: TypeIdent, Value: "x"},
+		{TokenType: TypeBracketL, Precedence: 100},
+		{TokenType: TypeNum, Value: "1"},
+		{TokenType: TypeColon},
+		{TokenType: TypeBracketR, Precedence: 100},
+	}
+
+	if !reflect.DeepEqual(out, expected) {
+		t.Error("unexpected token output")
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	lex := New(strings.NewReader("12 1.5 0.25e3 1E-2 2e+8 4e 5e-"))
+
+	var out []*Token
+
+	for {
+		tok, err := lex.NextToken()
+		if err != nil {
+			if err != ErrEOF {
+				t.Error("expected EOF error")
+			}
+			break
+		}
+		out = append(out, withoutPos(tok))
+	}
+
+	expected := []*Token{
+		{TokenType: TypeNum, Value: "12"},
+		{TokenType: TypeNum, Value: "1.5"},
+		{TokenType: TypeNum, Value: "0.25e3"},
+		{TokenType: TypeNum, Value: "1E-2"},
+		{TokenType: TypeNum, Value: "2e+8"},
+		{TokenType: TypeNum, Value: "4"},
+		{TokenType: TypeIdent, Value: "e"},
+		{TokenType: TypeNum, Value: "5"},
+		{TokenType: TypeIdent, Value: "e"},
+		{TokenType: TypeOp, Precedence: 4, Value: "-"},
+	}
+
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("unexpected token output %v", out)
+	}
+}
+
+func TestComments(t *testing.T) {
+	src := "x = 1 // one\n/* a /* nested */ comment */ y\n/* two\nlines */z / 2"
+
+	var skipped, preserved []*Token
+	for _, preserve := range []bool{false, true} {
+		lex := New(strings.NewReader(src))
+		lex.PreserveComments(preserve)
+		var out []*Token
+		for {
+			tok, err := lex.NextToken()
+			if err != nil {
+				if err != ErrEOF {
+					t.Errorf("expected EOF error, got %v", err)
+				}
+				break
+			}
+			out = append(out, withoutPos(tok))
+		}
+		if preserve {
+			preserved = out
+		} else {
+			skipped = out
+		}
+	}
+
+	expected := []*Token{
+		{TokenType: TypeIdent, Value: "x"},
+		{TokenType: TypeAssign, Precedence: 100},
+		{TokenType: TypeNum, Value: "1"},
+		{TokenType: TypeNewline, Precedence: 1000},
+		{TokenType: TypeIdent, Value: "y"},
+		{TokenType: TypeNewline, Precedence: 1000},
+		{TokenType: TypeNewline, Precedence: 1000}, // multi-line comment
+		{TokenType: TypeIdent, Value: "z"},
+		{TokenType: TypeOp, Precedence: 5, Value: "/"},
+		{TokenType: TypeNum, Value: "2"},
+	}
+	if !reflect.DeepEqual(skipped, expected) {
+		t.Errorf("unexpected token output %v", skipped)
+	}
+
+	expected = []*Token{
+		{TokenType: TypeIdent, Value: "x"},
+		{TokenType: TypeAssign, Precedence: 100},
+		{TokenType: TypeNum, Value: "1"},
+		{TokenType: TypeComment, Value: "// one"},
+		{TokenType: TypeNewline, Precedence: 1000},
+		{TokenType: TypeComment, Value: "/* a /* nested */ comment */"},
+		{TokenType: TypeIdent, Value: "y"},
+		{TokenType: TypeNewline, Precedence: 1000},
+		{TokenType: TypeComment, Value: "/* two\nlines */"},
+		{TokenType: TypeIdent, Value: "z"},
+		{TokenType: TypeOp, Precedence: 5, Value: "/"},
+		{TokenType: TypeNum, Value: "2"},
+	}
+	if !reflect.DeepEqual(preserved, expected) {
+		t.Errorf("unexpected token output %v", preserved)
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	lex := New(strings.NewReader("x /* a /* b */\n"))
+	lex.NextToken()
+	_, err := lex.NextToken()
+	lexErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected lexer error, got %v", err)
+	}
+	if lexErr.Pos.Column != 3 || lexErr.Msg != "unterminated block comment" {
+		t.Errorf("unexpected error %v", lexErr)
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		src, str string
+	}{
+		{`"plain"`, "plain"},
+		{`"a\"b\\c"`, `a"b\c`},
+		{`"\n\t\r"`, "\n\t\r"},
+		{`"\u{41}\u{1f600}"`, "A\U0001F600"},
+		{"`raw \\n\nnext`", "raw \\n\nnext"},
+	}
+
+	for _, test := range tests {
+		lex := New(strings.NewReader(test.src))
+		tok, err := lex.NextToken()
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+			continue
+		}
+		if tok.TokenType != TypeString || tok.Value != test.str {
+			t.Errorf("%s: unexpected token %v", test.src, tok)
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	lex := New(strings.NewReader(`"a ${b + "${c}"} d ${ {} } e" "$x"`))
+
+	var out []*Token
+
+	for {
+		tok, err := lex.NextToken()
+		if err != nil {
+			if err != ErrEOF {
+				t.Errorf("expected EOF error, got %v", err)
+			}
+			break
+		}
+		out = append(out, withoutPos(tok))
+	}
+
+	expected := []*Token{
+		{TokenType: TypeStringStart, Value: "a "},
+		{TokenType: TypeIdent, Value: "b"},
+		{TokenType: TypeOp, Precedence: 4, Value: "+"},
+		{TokenType: TypeStringStart, Value: ""},
+		{TokenType: TypeIdent, Value: "c"},
+		{TokenType: TypeStringEnd, Value: ""},
+		{TokenType: TypeStringMid, Value: " d "},
+		{TokenType: TypeBraceL},
+		{TokenType: TypeBraceR},
+		{TokenType: TypeStringEnd, Value: " e"},
+		{TokenType: TypeString, Value: "$x"},
+	}
+
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("unexpected token output %v", out)
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		col int
+		msg string
+		eof bool
+	}{
+		{`x = "abc`, 5, "unterminated string literal", true},
+		{"x = \"abc\ny\"", 5, "unterminated string literal", false},
+		{"x = `abc\n", 5, "unterminated string literal", true},
+		{`x = "a\qb"`, 7, `unknown escape sequence "\\q"`, false},
+		{`x = "\u{zz}"`, 6, `invalid unicode escape, expected \u{hex}`, false},
+		{`x = "\u{d800}"`, 6, "invalid unicode code point d800", false},
+	}
+
+	for _, test := range tests {
+		lex := New(strings.NewReader(test.src))
+		var err error
+		for err == nil {
+			_, err = lex.NextToken()
+		}
+		lexErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%q: expected lexer error, got %v", test.src, err)
+			continue
+		}
+		if lexErr.Pos.Column != test.col || lexErr.Msg != test.msg || lexErr.EOF != test.eof {
+			t.Errorf("%q: unexpected error %v (eof %v)", test.src, lexErr, lexErr.EOF)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	lex := NewFile("pos.tik", strings.NewReader("x = 12\n\tprint(\"hé\")"))
+
+	expected := []struct {
+		pos source.Pos
+		end source.Pos
+	}{
+		{source.Pos{Filename: "pos.tik", Offset: 0, Line: 1, Column: 1}, source.Pos{Filename: "pos.tik", Offset: 1, Line: 1, Column: 2}},
+		{source.Pos{Filename: "pos.tik", Offset: 2, Line: 1, Column: 3}, source.Pos{Filename: "pos.tik", Offset: 3, Line: 1, Column: 4}},
+		{source.Pos{Filename: "pos.tik", Offset: 4, Line: 1, Column: 5}, source.Pos{Filename: "pos.tik", Offset: 6, Line: 1, Column: 7}},
+		{source.Pos{Filename: "pos.tik", Offset: 6, Line: 1, Column: 7}, source.Pos{Filename: "pos.tik", Offset: 7, Line: 2, Column: 1}},
+		{source.Pos{Filename: "pos.tik", Offset: 8, Line: 2, Column: 2}, source.Pos{Filename: "pos.tik", Offset: 13, Line: 2, Column: 7}},
+		{source.Pos{Filename: "pos.tik", Offset: 13, Line: 2, Column: 7}, source.Pos{Filename: "pos.tik", Offset: 14, Line: 2, Column: 8}},
+		{source.Pos{Filename: "pos.tik", Offset: 14, Line: 2, Column: 8}, source.Pos{Filename: "pos.tik", Offset: 19, Line: 2, Column: 12}},
+		{source.Pos{Filename: "pos.tik", Offset: 19, Line: 2, Column: 12}, source.Pos{Filename: "pos.tik", Offset: 20, Line: 2, Column: 13}},
+	}
+
+	for i, exp := range expected {
+		tok, err := lex.NextToken()
+		if err != nil {
+			t.Fatalf("token %d: %v", i, err)
+		}
+		if tok.Pos != exp.pos || tok.End != exp.end {
+			t.Errorf("token %d %v: expected %v-%v got %v-%v", i, tok, exp.pos, exp.end, tok.Pos, tok.End)
+		}
+	}
+}
+
+// withoutPos strips the positions of a token, so that tests can focus on the token values.
+func withoutPos(tok *Token) *Token {
+	tok.Pos = source.Pos{}
+	tok.End = source.Pos{}
+	return tok
+}
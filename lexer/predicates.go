@@ -6,48 +6,94 @@ func isDigit(r rune) bool {
 	return '0' <= r && r <= '9'
 }
 
-var opMap = map[rune]bool{
-	'+': true,
-	'-': true,
-	'*': true,
-	'/': true,
+// opPrecedence contains all operators with their precedence for binary operations.
+// Higher values bind stronger.
+var opPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3,
+	"!=": 3,
+	"<":  3,
+	"<=": 3,
+	">":  3,
+	">=": 3,
+	"+":  4,
+	"-":  4,
+	"*":  5,
+	"/":  5,
+	"%":  5,
+	"!":  6,
 }
 
-func isOp(r rune) bool {
-	return opMap[r]
+func isOp(op string) bool {
+	_, ok := opPrecedence[op]
+	return ok
 }
 
-var opPrecedence = map[rune]int{
-	'+': 1,
-	'-': 1,
-	'*': 2,
-	'/': 2,
+func isOpStart(r rune) bool {
+	switch r {
+	case '|', '&', '=', '!', '<', '>', '+', '-', '*', '/', '%':
+		return true
+	}
+	return false
 }
 
 // All available keywords.
 const (
-	KWFunc   = "func"
-	KWPrint  = "print"
-	KWReturn = "return"
+	KWBreak    = "break"
+	KWConst    = "const"
+	KWContinue = "continue"
+	KWElse     = "else"
+	KWFalse    = "false"
+	KWFor      = "for"
+	KWFunc     = "func"
+	KWIf       = "if"
+	KWIn       = "in"
+	KWLet      = "let"
+	KWReturn   = "return"
+	KWTrue     = "true"
 )
 
 var keywords = map[string]bool{
-	KWFunc:   true,
-	KWPrint:  true,
-	KWReturn: true,
+	KWBreak:    true,
+	KWConst:    true,
+	KWContinue: true,
+	KWElse:     true,
+	KWFalse:    true,
+	KWFor:      true,
+	KWFunc:     true,
+	KWIf:       true,
+	KWIn:       true,
+	KWLet:      true,
+	KWReturn:   true,
+	KWTrue:     true,
 }
 
 func isKeyword(ident string) bool {
 	return keywords[ident]
 }
 
+// IsIdentifier reports whether name can be used as identifier, so that it
+// consists of the allowed characters and is no keyword.
+func IsIdentifier(name string) bool {
+	if name == "" || isKeyword(name) {
+		return false
+	}
+	for _, r := range name {
+		if !isIdent(r) {
+			return false
+		}
+	}
+	return true
+}
+
 func isIdent(r rune) bool {
 	ok, _ := regexp.MatchString("[a-z]", string(r))
 	return ok
 }
 
-func insideString(r rune) bool {
-	return r != '"'
+func isHexDigit(r rune) bool {
+	return isDigit(r) || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
 }
 
 func isWhitespace(r rune) bool {
@@ -1,6 +1,10 @@
 package lexer
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/pseidemann/tik/source"
+)
 
 // TokenType declares the token type.
 type TokenType int
@@ -11,14 +15,24 @@ const (
 	TypeIdent
 	TypeKeyword
 	TypeComma
+	TypeSemicolon
 	TypeNewline
 	TypeNum
 	TypeOp
-	TypeParenL // (
-	TypeParenR // )
-	TypeBraceL // {
-	TypeBraceR // }
+	TypeParenL   // (
+	TypeParenR   // )
+	TypeBraceL   // {
+	TypeBraceR   // }
+	TypeBracketL // [
+	TypeBracketR // ]
+	TypeColon
 	TypeString
+	TypeStringStart // string up to an embedded expression "${"
+	TypeStringMid   // string between two embedded expressions
+	TypeStringEnd   // string after the last embedded expression
+	TypeComment     // only returned if comments are preserved
+	TypeEOF         // end of input, only used by the parser
+	TypeInvalid     // unrecognized input, only used by the parser
 )
 
 var types = [...]string{
@@ -26,6 +40,7 @@ var types = [...]string{
 	"identifier",
 	"keyword",
 	"comma",
+	"semicolon",
 	"newline",
 	"number",
 	"operator",
@@ -33,16 +48,36 @@ var types = [...]string{
 	"paren-right",
 	"brace-left",
 	"brace-right",
+	"bracket-left",
+	"bracket-right",
+	"colon",
 	"string",
+	"string-start",
+	"string-middle",
+	"string-end",
+	"comment",
+	"end-of-file",
+	"invalid",
+}
+
+func (t TokenType) String() string {
+	return types[t]
+}
+
+// MarshalText encodes the token type by its name, e.g. for JSON.
+func (t TokenType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
 }
 
 // Token is a categorized lexeme.
 type Token struct {
-	TokenType  TokenType
-	Precedence int
-	Value      string
+	TokenType  TokenType  `json:"type"`
+	Precedence int        `json:"precedence"`
+	Value      string     `json:"value"`
+	Pos        source.Pos `json:"pos"` // position of the first character
+	End        source.Pos `json:"end"` // position immediately after the last character
 }
 
 func (t *Token) String() string {
-	return fmt.Sprintf("(%s<%d> %#v)", types[t.TokenType], t.Precedence, t.Value)
+	return fmt.Sprintf("(%s<%d> %#v)", t.TokenType, t.Precedence, t.Value)
 }
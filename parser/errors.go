@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pseidemann/tik/lexer"
+	"github.com/pseidemann/tik/source"
+)
+
+// Error is a syntax error.
+type Error struct {
+	Pos      source.Pos
+	Msg      string
+	Expected []lexer.TokenType // token types which would have been valid, if known
+	Actual   lexer.TokenType   // type of the offending token
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v: %s", e.Pos, e.Msg)
+}
+
+// ErrorList is the list of all syntax errors found in a source.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// describe returns a human readable description of the token for error messages.
+func describe(t *lexer.Token) string {
+	if t.Value == "" {
+		return t.TokenType.String()
+	}
+	return fmt.Sprintf("%s %q", t.TokenType, t.Value)
+}
+
+func joinTypes(types []lexer.TokenType) string {
+	strs := make([]string, len(types))
+	for i, t := range types {
+		strs[i] = t.String()
+	}
+	if len(strs) == 1 {
+		return strs[0]
+	}
+	return strings.Join(strs[:len(strs)-1], ", ") + " or " + strs[len(strs)-1]
+}
@@ -3,24 +3,46 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pseidemann/tik/ast"
 	"github.com/pseidemann/tik/lexer"
+	"github.com/pseidemann/tik/source"
 )
 
 var opMap = map[string]ast.OpType{
-	"+": ast.OpAdd,
-	"-": ast.OpSub,
-	"*": ast.OpMul,
-	"/": ast.OpDiv,
+	"+":  ast.OpAdd,
+	"-":  ast.OpSub,
+	"*":  ast.OpMul,
+	"/":  ast.OpDiv,
+	"%":  ast.OpMod,
+	"==": ast.OpEq,
+	"!=": ast.OpNe,
+	"<":  ast.OpLt,
+	"<=": ast.OpLe,
+	">":  ast.OpGt,
+	">=": ast.OpGe,
+	"&&": ast.OpAnd,
+	"||": ast.OpOr,
+}
+
+var unaryOpMap = map[string]ast.OpType{
+	"-": ast.OpNeg,
+	"!": ast.OpNot,
 }
 
 // Parser can parse tokens into an AST.
 type Parser struct {
-	lex     *lexer.Lexer
-	prevTok *lexer.Token
+	lex       *lexer.Lexer
+	unread    []*lexer.Token // tokens which were put back, last one is read first
+	errors    ErrorList
+	loopDepth int // number of loops enclosing the current statement inside the current function
+	open      int // number of unclosed list and map literals in the current statement
 }
 
+// bailout is raised to abort the current statement after a syntax error.
+type bailout struct{}
+
 // New creates a Parser.
 func New(lex *lexer.Lexer) *Parser {
 	return &Parser{
@@ -29,126 +51,489 @@ func New(lex *lexer.Lexer) *Parser {
 }
 
 // CreateAST generates an AST.
-func (p *Parser) CreateAST() ast.Node {
-	return p.parseImplicitBlock("main")
+// If the source contains syntax errors, the returned error is an ErrorList
+// with all of them and the AST contains only the statements which could be parsed.
+func (p *Parser) CreateAST() (ast.Node, error) {
+	root := p.parseImplicitBlock("main")
+	for {
+		t := p.nextToken()
+		if t.TokenType == lexer.TypeEOF {
+			break
+		}
+		// a closing brace without an opening one, which is reported already
+		// if it terminated a broken statement
+		if n := len(p.errors); n == 0 || p.errors[n-1].Pos != t.Pos {
+			p.addError(&Error{Pos: t.Pos, Msg: "unexpected " + describe(t), Actual: t.TokenType})
+		}
+		more := p.parseImplicitBlock("main")
+		root.Stmts = append(root.Stmts, more.Stmts...)
+	}
+	if len(root.Stmts) > 0 {
+		root.From = root.Stmts[0].Pos()
+		root.To = root.Stmts[len(root.Stmts)-1].End()
+	}
+	if len(p.errors) > 0 {
+		return root, p.errors
+	}
+	return root, nil
 }
 
-func (p *Parser) nextToken() (*lexer.Token, error) {
-	if p.prevTok != nil {
-		t := p.prevTok
-		p.prevTok = nil
+// readToken returns the next token. At the end of the input, it returns a token of type TypeEOF.
+func (p *Parser) readToken() (*lexer.Token, error) {
+	if l := len(p.unread); l > 0 {
+		t := p.unread[l-1]
+		p.unread = p.unread[:l-1]
 		return t, nil
 	}
 	t, err := p.lex.NextToken()
-	// fmt.Println("next token", t)
+	if err == lexer.ErrEOF {
+		pos := p.lex.Pos()
+		return &lexer.Token{TokenType: lexer.TypeEOF, Pos: pos, End: pos}, nil
+	}
+	if err == nil && t.TokenType == lexer.TypeComment {
+		// the lexer preserves comments, which aren't part of the AST
+		if strings.Contains(t.Value, "\n") {
+			return &lexer.Token{TokenType: lexer.TypeNewline, Precedence: 1000, Pos: t.Pos, End: t.End}, nil
+		}
+		return p.readToken()
+	}
 	return t, err
 }
 
+func (p *Parser) nextToken() *lexer.Token {
+	t, err := p.readToken()
+	if err != nil {
+		p.addLexError(err)
+		panic(bailout{})
+	}
+	return t
+}
+
 func (p *Parser) unreadToken(t *lexer.Token) {
-	p.prevTok = t
+	p.unread = append(p.unread, t)
+}
+
+func (p *Parser) peek() *lexer.Token {
+	t := p.nextToken()
+	p.unreadToken(t)
+	return t
+}
+
+func (p *Parser) addError(e *Error) {
+	p.errors = append(p.errors, e)
+}
+
+func (p *Parser) addLexError(err error) {
+	if lexErr, ok := err.(*lexer.Error); ok {
+		actual := lexer.TypeInvalid
+		if lexErr.EOF {
+			actual = lexer.TypeEOF
+		}
+		p.addError(&Error{Pos: lexErr.Pos, Msg: lexErr.Msg, Actual: actual})
+		return
+	}
+	p.addError(&Error{Pos: p.lex.Pos(), Msg: err.Error(), Actual: lexer.TypeInvalid})
+}
+
+// failf records a syntax error at the given position and aborts the current statement.
+func (p *Parser) failf(pos source.Pos, actual lexer.TokenType, format string, args ...interface{}) {
+	p.addError(&Error{Pos: pos, Msg: fmt.Sprintf(format, args...), Actual: actual})
+	panic(bailout{})
+}
+
+// unexpected records a syntax error for the given token and aborts the current statement.
+// The token is put back, so that the statement can be skipped properly.
+func (p *Parser) unexpected(t *lexer.Token, expected ...lexer.TokenType) {
+	p.unreadToken(t)
+	msg := "unexpected " + describe(t)
+	if len(expected) > 0 {
+		msg = fmt.Sprintf("expected %s, got %s", joinTypes(expected), describe(t))
+	}
+	p.addError(&Error{Pos: t.Pos, Msg: msg, Expected: expected, Actual: t.TokenType})
+	panic(bailout{})
 }
 
 func (p *Parser) parseImplicitBlock(name string) *ast.Block {
 	var stmts []ast.Node
 	for {
-		s := p.parseStmt()
-		if s == nil {
+		s, more := p.parseStmtOrSkip()
+		if s != nil {
+			stmts = append(stmts, s)
+		}
+		if !more {
 			break
 		}
-		stmts = append(stmts, s)
 	}
-	return &ast.Block{
+	b := &ast.Block{
 		Name:  name,
 		Stmts: stmts,
 	}
+	if len(stmts) > 0 {
+		b.From = stmts[0].Pos()
+		b.To = stmts[len(stmts)-1].End()
+	}
+	return b
 }
 
 func (p *Parser) parseBlock(name string) *ast.Block {
-	p.getToken(lexer.TypeBraceL)
+	lbrace := p.getToken(lexer.TypeBraceL)
 	n := p.parseImplicitBlock(name)
-	p.getToken(lexer.TypeBraceR)
+	rbrace := p.getToken(lexer.TypeBraceR)
+	n.From = lbrace.Pos
+	n.To = rbrace.End
 	return n
 }
 
-func (p *Parser) parseStmt() ast.Node {
-	t, err := p.nextToken()
-	if err != nil {
-		if err != lexer.ErrEOF {
-			fmt.Println("ERROR:", err)
+// parseStmtOrSkip parses the next statement of a block.
+// After a syntax error, the rest of the statement is skipped.
+// It reports whether more statements may follow in the block.
+func (p *Parser) parseStmtOrSkip() (n ast.Node, more bool) {
+	defer func(open int) {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+			n, more = nil, p.skipStmt(p.open)
 		}
-		return nil
+		p.open = open
+	}(p.open)
+	p.open = 0
+	n = p.parseStmt()
+	if n == nil {
+		return nil, false
 	}
+	p.endStmt()
+	return n, true
+}
+
+// endStmt makes sure that a statement is followed by a newline or the end of the block.
+func (p *Parser) endStmt() {
+	t := p.nextToken()
+	switch t.TokenType {
+	case lexer.TypeNewline:
+	case lexer.TypeBraceR, lexer.TypeEOF:
+		p.unreadToken(t)
+	default:
+		p.unexpected(t, lexer.TypeNewline)
+	}
+}
+
+// skipStmt discards tokens up to the end of the current statement, so that parsing can
+// continue after a syntax error. Blocks, lists and maps inside the statement are skipped
+// as a whole, including the given number of already opened ones.
+// It reports whether more statements may follow in the current block.
+func (p *Parser) skipStmt(depth int) bool {
+	for {
+		t, err := p.readToken()
+		if err != nil {
+			p.addLexError(err)
+			continue
+		}
+		switch t.TokenType {
+		case lexer.TypeEOF:
+			p.unreadToken(t)
+			return false
+		case lexer.TypeBraceL, lexer.TypeBracketL:
+			depth++
+		case lexer.TypeBracketR:
+			if depth > 0 {
+				depth--
+			}
+		case lexer.TypeBraceR:
+			if depth == 0 {
+				p.unreadToken(t)
+				return false
+			}
+			depth--
+		case lexer.TypeNewline:
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+}
+
+func (p *Parser) parseStmt() ast.Node {
+	t := p.nextToken()
 	switch t.TokenType {
 	case lexer.TypeKeyword:
 		switch t.Value {
 		case lexer.KWFunc:
-			return p.parseFuncDef()
-		case lexer.KWPrint:
-			return p.parseFuncCall(t.Value)
+			if p.peek().TokenType == lexer.TypeParenL {
+				// anonymous function, e.g. called immediately
+				p.unreadToken(t)
+				return p.parseSimpleStmt()
+			}
+			return p.parseFuncDef(t)
+		case lexer.KWIf:
+			return p.parseIf(t)
+		case lexer.KWFor:
+			return p.parseFor(t)
+		case lexer.KWBreak:
+			if p.loopDepth == 0 {
+				p.failf(t.Pos, t.TokenType, "break outside of loop")
+			}
+			return &ast.Break{Span: ast.Span{From: t.Pos, To: t.End}}
+		case lexer.KWContinue:
+			if p.loopDepth == 0 {
+				p.failf(t.Pos, t.TokenType, "continue outside of loop")
+			}
+			return &ast.Continue{Span: ast.Span{From: t.Pos, To: t.End}}
 		case lexer.KWReturn:
 			expr, ok := p.parseExpr()
 			if !ok {
-				return &ast.Return{}
+				return &ast.Return{
+					Span: ast.Span{From: t.Pos, To: t.End},
+				}
 			}
 			return &ast.Return{
+				Span:  ast.Span{From: t.Pos, To: expr.End()},
 				Value: expr,
 			}
-		default:
-			panic("unknown keyword " + t.Value)
-		}
-	case lexer.TypeIdent:
-		next, err := p.nextToken()
-		if err != nil {
-			if err != lexer.ErrEOF {
-				fmt.Println("ERROR:", err)
-			}
-		}
-		p.unreadToken(next)
-		switch next.TokenType {
-		case lexer.TypeParenL:
-			return p.parseFuncCall(t.Value)
-		case lexer.TypeAssign:
-			return p.parseAssign(t.Value)
-		default:
-			panic(fmt.Sprintf("unexpected token %v", t))
 		}
 	case lexer.TypeNewline:
 		return p.parseStmt()
-	case lexer.TypeBraceR:
+	case lexer.TypeBraceR, lexer.TypeEOF:
 		// end of block
 		p.unreadToken(t)
-	default:
-		panic(fmt.Sprintf("unexpected token %v", t))
-
+		return nil
 	}
-	return nil
+
+	// assignment or expression statement
+	p.unreadToken(t)
+	return p.parseSimpleStmt()
 }
 
-func (p *Parser) parseFuncDef() ast.Node {
+func (p *Parser) parseFuncDef(kw *lexer.Token) ast.Node {
 	ident := p.getToken(lexer.TypeIdent)
-	p.getToken(lexer.TypeParenL)
-	params := p.parseParamsList()
-	p.getToken(lexer.TypeParenR)
+	params, body := p.parseFunc()
 	return &ast.FuncDef{
+		Span:   ast.Span{From: kw.Pos, To: body.End()},
 		Name:   ident.Value,
 		Params: params,
-		Body:   p.parseBlock("func"),
+		Body:   body,
+	}
+}
+
+func (p *Parser) parseFuncLit(kw *lexer.Token) ast.Node {
+	params, body := p.parseFunc()
+	return &ast.FuncLit{
+		Span:   ast.Span{From: kw.Pos, To: body.End()},
+		Params: params,
+		Body:   body,
+	}
+}
+
+// parseFunc parses the parameters and the body of a function.
+func (p *Parser) parseFunc() ([]*ast.Param, *ast.Block) {
+	p.getToken(lexer.TypeParenL)
+	params := p.parseParamsList()
+	p.getToken(lexer.TypeParenR)
+	// loops around the definition can't be controlled from inside the function
+	defer func(loopDepth int) {
+		p.loopDepth = loopDepth
+	}(p.loopDepth)
+	p.loopDepth = 0
+	return params, p.parseBlock("func")
+}
+
+func (p *Parser) parseIf(kw *lexer.Token) ast.Node {
+	cond := p.parseCond(kw)
+	then := p.parseBlock("if")
+	n := &ast.If{
+		Span: ast.Span{From: kw.Pos, To: then.End()},
+		Cond: cond,
+		Then: then,
+	}
+
+	// like in Go, else must follow on the same line as the closing brace
+	next := p.peek()
+	if next.TokenType != lexer.TypeKeyword || next.Value != lexer.KWElse {
+		return n
+	}
+	p.nextToken()
+	after := p.peek()
+	if after.TokenType == lexer.TypeKeyword && after.Value == lexer.KWIf {
+		p.nextToken()
+		n.Else = p.parseIf(after)
+	} else {
+		n.Else = p.parseBlock("else")
+	}
+	n.To = n.Else.End()
+	return n
+}
+
+// parseFor parses the forms "for {}", "for cond {}" and "for init; cond; post {}".
+func (p *Parser) parseFor(kw *lexer.Token) ast.Node {
+	if vars := p.parseForInVars(); vars != nil {
+		return p.parseForIn(kw, vars)
+	}
+
+	n := &ast.For{
+		Span: ast.Span{From: kw.Pos},
+	}
+
+	if p.peek().TokenType != lexer.TypeBraceL {
+		first := p.parseSimpleStmt()
+		if p.peek().TokenType == lexer.TypeSemicolon {
+			p.nextToken()
+			n.Init = p.checkSimpleStmt(first)
+			if p.peek().TokenType != lexer.TypeSemicolon {
+				n.Cond = p.parseCond(kw)
+			}
+			p.getToken(lexer.TypeSemicolon)
+			if p.peek().TokenType != lexer.TypeBraceL {
+				n.Post = p.checkSimpleStmt(p.parseSimpleStmt())
+			}
+		} else {
+			if _, ok := first.(*ast.Assign); ok {
+				p.failf(first.Pos(), lexer.TypeAssign, "expected condition after for, got assignment")
+			}
+			n.Cond = first
+		}
+	}
+
+	p.loopDepth++
+	defer func() {
+		p.loopDepth--
+	}()
+	n.Body = p.parseBlock("for")
+	n.To = n.Body.End()
+	return n
+}
+
+// parseForInVars parses the variables and the "in" of a for-in loop like "for k, v in m".
+// If the tokens don't start a for-in loop, they are put back and nil is returned.
+func (p *Parser) parseForInVars() []*ast.Ident {
+	var read []*lexer.Token
+	putBack := func() []*ast.Ident {
+		for i := len(read) - 1; i >= 0; i-- {
+			p.unreadToken(read[i])
+		}
+		return nil
+	}
+
+	var vars []*ast.Ident
+	for {
+		t := p.nextToken()
+		read = append(read, t)
+		if t.TokenType != lexer.TypeIdent {
+			return putBack()
+		}
+		vars = append(vars, &ast.Ident{
+			Span: ast.Span{From: t.Pos, To: t.End},
+			Name: t.Value,
+		})
+		t = p.nextToken()
+		read = append(read, t)
+		switch {
+		case t.TokenType == lexer.TypeKeyword && t.Value == lexer.KWIn:
+			return vars
+		case t.TokenType == lexer.TypeComma && len(vars) == 1:
+			continue
+		default:
+			return putBack()
+		}
+	}
+}
+
+func (p *Parser) parseForIn(kw *lexer.Token, vars []*ast.Ident) ast.Node {
+	iter, ok := p.parseExpr()
+	if !ok {
+		t := p.peek()
+		p.failf(t.Pos, t.TokenType, "expected expression after in, got %s", describe(t))
+	}
+
+	p.loopDepth++
+	defer func() {
+		p.loopDepth--
+	}()
+	body := p.parseBlock("for")
+	return &ast.ForIn{
+		Span: ast.Span{From: kw.Pos, To: body.End()},
+		Vars: vars,
+		Iter: iter,
+		Body: body,
+	}
+}
+
+// parseSimpleStmt parses a declaration, an assignment or an expression.
+func (p *Parser) parseSimpleStmt() ast.Node {
+	t := p.nextToken()
+	if t.TokenType == lexer.TypeKeyword && (t.Value == lexer.KWLet || t.Value == lexer.KWConst) {
+		return p.parseDecl(t)
+	}
+	p.unreadToken(t)
+	expr, ok := p.parseExpr()
+	if !ok {
+		t := p.peek()
+		p.failf(t.Pos, t.TokenType, "expected statement, got %s", describe(t))
+	}
+	if t := p.peek(); t.TokenType == lexer.TypeAssign {
+		switch expr.(type) {
+		case *ast.Ident, *ast.Index:
+			return p.parseAssign(expr)
+		}
+		p.failf(t.Pos, t.TokenType, "cannot assign to %v", expr)
 	}
+	return expr
+}
+
+// checkSimpleStmt makes sure that the init and post statements of a for loop have an effect.
+func (p *Parser) checkSimpleStmt(n ast.Node) ast.Node {
+	switch n.(type) {
+	case *ast.Decl, *ast.Assign, *ast.FuncCall:
+		return n
+	}
+	p.failf(n.Pos(), lexer.TypeInvalid, "expected assignment or function call, got %v", n)
+	return nil
+}
+
+// parseDecl parses a variable declaration after its keyword.
+func (p *Parser) parseDecl(kw *lexer.Token) ast.Node {
+	ident := p.getToken(lexer.TypeIdent)
+	p.getToken(lexer.TypeAssign)
+	exp, ok := p.parseExpr()
+	if !ok {
+		t := p.peek()
+		p.failf(t.Pos, t.TokenType, "expected expression on right side of declaration, got %s", describe(t))
+	}
+	return &ast.Decl{
+		Span: ast.Span{From: kw.Pos, To: exp.End()},
+		Name: &ast.Ident{
+			Span: ast.Span{From: ident.Pos, To: ident.End},
+			Name: ident.Value,
+		},
+		Value: exp,
+		Const: kw.Value == lexer.KWConst,
+	}
+}
+
+// parseCond parses the condition of a control flow statement.
+func (p *Parser) parseCond(kw *lexer.Token) ast.Node {
+	if t := p.peek(); t.TokenType == lexer.TypeBraceL {
+		// a block, not a map literal
+		p.failf(t.Pos, t.TokenType, "expected condition after %s, got %s", kw.Value, describe(t))
+	}
+	cond, ok := p.parseExpr()
+	if !ok {
+		t := p.peek()
+		p.failf(t.Pos, t.TokenType, "expected condition after %s, got %s", kw.Value, describe(t))
+	}
+	return cond
 }
 
 func (p *Parser) parseParamsList() []*ast.Param {
 	var params []*ast.Param
 	for {
-		t, err := p.nextToken()
-		if err != nil {
-			panic(err)
-		}
+		t := p.nextToken()
 		switch t.TokenType {
 		case lexer.TypeParenR:
 			p.unreadToken(t)
 			return params
 		case lexer.TypeIdent:
 			params = append(params, &ast.Param{
+				Span: ast.Span{From: t.Pos, To: t.End},
 				Name: t.Value,
 			})
 			after := p.getToken(lexer.TypeComma, lexer.TypeParenR)
@@ -157,21 +542,56 @@ func (p *Parser) parseParamsList() []*ast.Param {
 				return params
 			}
 		default:
-			panic("expected ident, comma or paren right, got " + t.String())
+			p.unexpected(t, lexer.TypeIdent, lexer.TypeParenR)
 		}
 	}
 }
 
-func (p *Parser) parseFuncCall(name string) ast.Node {
+// parseCall parses the arguments of a call of the given function.
+func (p *Parser) parseCall(fn ast.Node) ast.Node {
 	p.getToken(lexer.TypeParenL)
 	args := p.parseExprList()
-	p.getToken(lexer.TypeParenR)
+	rparen := p.getToken(lexer.TypeParenR)
 	return &ast.FuncCall{
-		Name: name,
+		Span: ast.Span{From: fn.Pos(), To: rparen.End},
+		Func: fn,
 		Args: args,
 	}
 }
 
+// parseInterpolation parses the embedded expressions and literal parts of a string
+// starting with the given token. Empty literal parts are omitted.
+func (p *Parser) parseInterpolation(start *lexer.Token) ast.Node {
+	interp := &ast.Interpolation{Span: ast.Span{From: start.Pos}}
+	t := start
+	for {
+		if t.Value != "" {
+			interp.Parts = append(interp.Parts, &ast.String{
+				Span: ast.Span{From: t.Pos, To: t.End},
+				Str:  t.Value,
+			})
+		}
+		if t.TokenType == lexer.TypeStringEnd {
+			break
+		}
+
+		exp, ok := p.parseExpr()
+		if !ok {
+			next := p.peek()
+			p.failf(next.Pos, next.TokenType, "expected expression, got %s", describe(next))
+		}
+		interp.Parts = append(interp.Parts, exp)
+
+		t = p.nextToken()
+		if t.TokenType != lexer.TypeStringMid && t.TokenType != lexer.TypeStringEnd {
+			p.unreadToken(t)
+			p.failf(t.Pos, t.TokenType, "expected } after embedded expression, got %s", describe(t))
+		}
+	}
+	interp.To = t.End
+	return interp
+}
+
 func (p *Parser) parseExprList() []ast.Node {
 	var exps []ast.Node
 	for {
@@ -189,138 +609,287 @@ func (p *Parser) parseExprList() []ast.Node {
 	return exps
 }
 
-// parseExpr implements the shunting-yard algorithm.
+// parseExpr implements the shunting-yard algorithm for binary operators.
+// Operands are parsed by parseOperand.
 func (p *Parser) parseExpr() (ast.Node, bool) {
 	var outQueue []ast.Node
 	var opStack tokenStack
-	var nestingLevel int
 
-Loop:
+	first, ok := p.parseOperand()
+	if !ok {
+		return nil, false
+	}
+	outQueue = append(outQueue, first)
+
 	for {
-		t, err := p.nextToken()
-		if err != nil {
-			if err != lexer.ErrEOF {
-				panic(err)
-			}
+		t := p.nextToken()
+		if _, ok := opMap[t.Value]; t.TokenType != lexer.TypeOp || !ok {
+			// end of expression
+			p.unreadToken(t)
 			break
 		}
-
-		switch t.TokenType {
-		case lexer.TypeComma, lexer.TypeNewline:
-			p.unreadToken(t)
-			break Loop
-		case lexer.TypeNum:
-			outQueue = append(outQueue, &ast.Number{
-				Num: t.Value,
-			})
-		case lexer.TypeString:
-			outQueue = append(outQueue, &ast.String{
-				Str: t.Value,
-			})
-		case lexer.TypeIdent:
-			next, err := p.nextToken()
-			if err != nil {
-				if err != lexer.ErrEOF {
-					fmt.Println("ERROR:", err)
-				}
-			}
-			p.unreadToken(next)
-			switch next.TokenType {
-			case lexer.TypeParenL:
-				outQueue = append(outQueue, p.parseFuncCall(t.Value))
-			default:
-				outQueue = append(outQueue, &ast.Ident{
-					Name: t.Value,
-				})
-			}
-		case lexer.TypeOp:
-			for opStack.peek() != nil &&
-				opStack.peek().TokenType == lexer.TypeOp &&
-				opStack.peek().Precedence >= t.Precedence {
-				popped := opStack.pop()
-				fmt.Println("move from stack to queue", popped)
-				outQueue = queueOp(outQueue, popped)
-			}
-			opStack.push(t)
-		case lexer.TypeParenL:
-			nestingLevel++
-			opStack.push(t)
-		case lexer.TypeParenR:
-			if nestingLevel == 0 {
-				// we got the closing parenthesis from a function call
-				p.unreadToken(t)
-				break Loop
-			}
-			nestingLevel--
-			for opStack.peek() != nil && opStack.peek().TokenType != lexer.TypeParenL {
-				popped := opStack.pop()
-				outQueue = queueOp(outQueue, popped)
-			}
-			// pop the left parenthesis from the stack
+		for opStack.peek() != nil && opStack.peek().Precedence >= t.Precedence {
 			popped := opStack.pop()
-			if popped == nil || popped.TokenType != lexer.TypeParenL {
-				panic("unbalanced parenthesis")
-			}
-		default:
-			panic(fmt.Sprintf("unexpected token %v", t))
+			outQueue = p.queueOp(outQueue, popped)
+		}
+		opStack.push(t)
+
+		operand, ok := p.parseOperand()
+		if !ok {
+			p.failf(t.Pos, t.TokenType, "missing operand for operator %s", t.Value)
 		}
+		outQueue = append(outQueue, operand)
 	}
 
 	for opStack.peek() != nil {
 		popped := opStack.pop()
-		outQueue = queueOp(outQueue, popped)
+		outQueue = p.queueOp(outQueue, popped)
 	}
 
-	if len(outQueue) == 0 {
+	return outQueue[0], true
+}
+
+// parseOperand parses a primary expression followed by any number of
+// calls, index or slice expressions like f(x)[i][a:b].
+// It reports false if the current token doesn't start an operand.
+func (p *Parser) parseOperand() (ast.Node, bool) {
+	n, ok := p.parsePrimary()
+	if !ok {
 		return nil, false
 	}
+	for {
+		switch p.peek().TokenType {
+		case lexer.TypeBracketL:
+			n = p.parseIndex(n)
+		case lexer.TypeParenL:
+			n = p.parseCall(n)
+		default:
+			return n, true
+		}
+	}
+}
 
-	return outQueue[0], true
+// parsePrimary parses a literal, a variable, an anonymous function, an expression
+// in parentheses or a unary operation.
+// It reports false if the current token doesn't start an operand.
+func (p *Parser) parsePrimary() (ast.Node, bool) {
+	t := p.nextToken()
+
+	switch t.TokenType {
+	case lexer.TypeNum:
+		return &ast.Number{
+			Span: ast.Span{From: t.Pos, To: t.End},
+			Num:  t.Value,
+		}, true
+	case lexer.TypeString:
+		return &ast.String{
+			Span: ast.Span{From: t.Pos, To: t.End},
+			Str:  t.Value,
+		}, true
+	case lexer.TypeStringStart:
+		return p.parseInterpolation(t), true
+	case lexer.TypeBracketL:
+		return p.parseList(t), true
+	case lexer.TypeBraceL:
+		// blocks only follow keywords, so a brace in an expression starts a map
+		return p.parseMap(t), true
+	case lexer.TypeKeyword:
+		switch t.Value {
+		case lexer.KWTrue, lexer.KWFalse:
+			return &ast.Bool{
+				Span:  ast.Span{From: t.Pos, To: t.End},
+				Value: t.Value == lexer.KWTrue,
+			}, true
+		case lexer.KWFunc:
+			return p.parseFuncLit(t), true
+		}
+	case lexer.TypeIdent:
+		return &ast.Ident{
+			Span: ast.Span{From: t.Pos, To: t.End},
+			Name: t.Value,
+		}, true
+	case lexer.TypeParenL:
+		inner, ok := p.parseExpr()
+		if !ok {
+			next := p.peek()
+			p.failf(next.Pos, next.TokenType, "expected expression, got %s", describe(next))
+		}
+		if p.peek().TokenType != lexer.TypeParenR {
+			p.failf(t.Pos, t.TokenType, "unbalanced parenthesis")
+		}
+		p.nextToken()
+		return inner, true
+	case lexer.TypeOp:
+		opType, ok := unaryOpMap[t.Value]
+		if !ok {
+			p.failf(t.Pos, t.TokenType, "missing operand for operator %s", t.Value)
+		}
+		operand, ok := p.parseOperand()
+		if !ok {
+			p.failf(t.Pos, t.TokenType, "missing operand for operator %s", t.Value)
+		}
+		return &ast.UnaryOperation{
+			Span:    ast.Span{From: t.Pos, To: operand.End()},
+			OpType:  opType,
+			Operand: operand,
+		}, true
+	}
+
+	p.unreadToken(t)
+	return nil, false
 }
 
-func queueOp(queue []ast.Node, op *lexer.Token) []ast.Node {
-	l := len(queue)
-	var left, right ast.Node
+// parseList parses the elements of a list literal after its '['.
+// The elements may be spread over multiple lines and followed by a trailing comma.
+func (p *Parser) parseList(lbracket *lexer.Token) ast.Node {
+	list := &ast.List{Span: ast.Span{From: lbracket.Pos}}
+	p.open++ // stays incremented after a syntax error, see parseStmtOrSkip
+	for {
+		p.skipNewlines()
+		if t := p.peek(); t.TokenType == lexer.TypeBracketR {
+			p.nextToken()
+			list.To = t.End
+			p.open--
+			return list
+		}
+		elem, ok := p.parseExpr()
+		if !ok {
+			next := p.peek()
+			p.failf(next.Pos, next.TokenType, "expected expression, got %s", describe(next))
+		}
+		list.Elems = append(list.Elems, elem)
+		p.skipNewlines()
+		t := p.getToken(lexer.TypeComma, lexer.TypeBracketR)
+		if t.TokenType == lexer.TypeBracketR {
+			list.To = t.End
+			p.open--
+			return list
+		}
+	}
+}
+
+// parseMap parses the entries of a map literal after its '{'.
+// Like lists, the entries may be spread over multiple lines and followed by a trailing comma.
+func (p *Parser) parseMap(lbrace *lexer.Token) ast.Node {
+	m := &ast.Map{Span: ast.Span{From: lbrace.Pos}}
+	p.open++ // stays incremented after a syntax error, see parseStmtOrSkip
+	for {
+		p.skipNewlines()
+		if t := p.peek(); t.TokenType == lexer.TypeBraceR {
+			p.nextToken()
+			m.To = t.End
+			p.open--
+			return m
+		}
+		key, ok := p.parseExpr()
+		if !ok {
+			next := p.peek()
+			p.failf(next.Pos, next.TokenType, "expected map key, got %s", describe(next))
+		}
+		p.getToken(lexer.TypeColon)
+		p.skipNewlines()
+		value, ok := p.parseExpr()
+		if !ok {
+			next := p.peek()
+			p.failf(next.Pos, next.TokenType, "expected map value, got %s", describe(next))
+		}
+		m.Keys = append(m.Keys, key)
+		m.Values = append(m.Values, value)
+		p.skipNewlines()
+		t := p.getToken(lexer.TypeComma, lexer.TypeBraceR)
+		if t.TokenType == lexer.TypeBraceR {
+			m.To = t.End
+			p.open--
+			return m
+		}
+	}
+}
+
+func (p *Parser) skipNewlines() {
+	for {
+		t := p.nextToken()
+		if t.TokenType != lexer.TypeNewline {
+			p.unreadToken(t)
+			return
+		}
+	}
+}
 
-	if l > 0 {
-		right, queue = queue[l-1], queue[:l-1]
-		l = len(queue)
+// parseIndex parses an index expression like xs[i] or a slice expression like xs[a:b]
+// following the given operand. The bounds of a slice are optional.
+func (p *Parser) parseIndex(left ast.Node) ast.Node {
+	p.getToken(lexer.TypeBracketL)
+	var low ast.Node
+	if p.peek().TokenType != lexer.TypeColon {
+		var ok bool
+		low, ok = p.parseExpr()
+		if !ok {
+			next := p.peek()
+			p.failf(next.Pos, next.TokenType, "expected index, got %s", describe(next))
+		}
+	}
+	t := p.getToken(lexer.TypeColon, lexer.TypeBracketR)
+	if t.TokenType == lexer.TypeBracketR {
+		return &ast.Index{
+			Span:  ast.Span{From: left.Pos(), To: t.End},
+			Left:  left,
+			Index: low,
+		}
 	}
 
-	if l > 0 {
-		left, queue = queue[l-1], queue[:l-1]
+	var high ast.Node
+	if p.peek().TokenType != lexer.TypeBracketR {
+		var ok bool
+		high, ok = p.parseExpr()
+		if !ok {
+			next := p.peek()
+			p.failf(next.Pos, next.TokenType, "expected slice bound, got %s", describe(next))
+		}
 	}
+	t = p.getToken(lexer.TypeBracketR)
+	return &ast.Slice{
+		Span: ast.Span{From: left.Pos(), To: t.End},
+		Left: left,
+		Low:  low,
+		High: high,
+	}
+}
+
+func (p *Parser) queueOp(queue []ast.Node, op *lexer.Token) []ast.Node {
+	l := len(queue)
+	left, right := queue[l-2], queue[l-1]
+	queue = queue[:l-2]
 
 	return append(queue, &ast.Operation{
+		Span:   ast.Span{From: left.Pos(), To: right.End()},
 		OpType: opMap[op.Value],
 		Left:   left,
 		Right:  right,
 	})
 }
 
-func (p *Parser) parseAssign(variable string) ast.Node {
+// parseAssign parses the assignment to a variable or list element.
+func (p *Parser) parseAssign(left ast.Node) ast.Node {
 	p.getToken(lexer.TypeAssign)
 	exp, ok := p.parseExpr()
 	if !ok {
-		panic("expected expression on right side of assignment")
+		t := p.peek()
+		p.failf(t.Pos, t.TokenType, "expected expression on right side of assignment, got %s", describe(t))
 	}
 	return &ast.Assign{
-		Left: &ast.Ident{
-			Name: variable,
-		},
+		Span:  ast.Span{From: left.Pos(), To: exp.End()},
+		Left:  left,
 		Right: exp,
 	}
 }
 
 func (p *Parser) getToken(tokenTypes ...lexer.TokenType) *lexer.Token {
-	t, err := p.nextToken()
-	if err != nil {
-		panic(err)
-	}
+	t := p.nextToken()
 	for _, expected := range tokenTypes {
 		if t.TokenType == expected {
 			return t
 		}
 	}
-	panic(fmt.Sprintf("expected token %v got %s (%s)", tokenTypes, t.TokenType, t.Value))
+	p.unexpected(t, tokenTypes...)
+	return nil
 }
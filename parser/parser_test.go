@@ -3,10 +3,12 @@ package parser
 import (
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/pseidemann/tik/ast"
 	"github.com/pseidemann/tik/lexer"
+	"github.com/pseidemann/tik/source"
 )
 
 func TestFuncSimple(t *testing.T) {
@@ -16,7 +18,11 @@ func TestFuncSimple(t *testing.T) {
 	}
 	lex := lexer.New(f)
 	par := New(lex)
-	a := par.CreateAST()
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearSpans(a)
 
 	expected := &ast.Block{
 		Name: "main",
@@ -27,7 +33,7 @@ func TestFuncSimple(t *testing.T) {
 					Name: "func",
 					Stmts: []ast.Node{
 						&ast.FuncCall{
-							Name: "print",
+							Func: &ast.Ident{Name: "print"},
 							Args: []ast.Node{
 								&ast.String{Str: "Hello, world!"},
 							},
@@ -36,7 +42,191 @@ func TestFuncSimple(t *testing.T) {
 				},
 			},
 			&ast.FuncCall{
-				Name: "greet",
+				Func: &ast.Ident{Name: "greet"},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(a, expected) {
+		t.Error("unexpected AST")
+	}
+}
+
+func TestFuncValues(t *testing.T) {
+	src := "f(1)(2)\nfunc() {\n}()\ng = func(x) {\n\treturn x\n}\n"
+	lex := lexer.New(strings.NewReader(src))
+	par := New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearSpans(a)
+
+	expected := &ast.Block{
+		Name: "main",
+		Stmts: []ast.Node{
+			&ast.FuncCall{
+				Func: &ast.FuncCall{
+					Func: &ast.Ident{Name: "f"},
+					Args: []ast.Node{&ast.Number{Num: "1"}},
+				},
+				Args: []ast.Node{&ast.Number{Num: "2"}},
+			},
+			&ast.FuncCall{
+				Func: &ast.FuncLit{Body: &ast.Block{Name: "func"}},
+			},
+			&ast.Assign{
+				Left: &ast.Ident{Name: "g"},
+				Right: &ast.FuncLit{
+					Params: []*ast.Param{{Name: "x"}},
+					Body: &ast.Block{
+						Name:  "func",
+						Stmts: []ast.Node{&ast.Return{Value: &ast.Ident{Name: "x"}}},
+					},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(a, expected) {
+		t.Error("unexpected AST")
+	}
+}
+
+func TestDecl(t *testing.T) {
+	src := "let x = 1\nfor let i = 0; i < x; i = i + 1 {\n}\nlet = 2\nlet y\nlet p = print\n"
+	lex := lexer.New(strings.NewReader(src))
+	par := New(lex)
+	a, err := par.CreateAST()
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected two errors, got %v", err)
+	}
+	if errs[0].Msg != "expected identifier, got assignment" || errs[1].Msg != "expected assignment, got newline" {
+		t.Errorf("unexpected errors %v", errs)
+	}
+
+	clearSpans(a)
+	x, i := &ast.Ident{Name: "x"}, &ast.Ident{Name: "i"}
+	expected := &ast.Block{
+		Name: "main",
+		Stmts: []ast.Node{
+			&ast.Decl{Name: x, Value: &ast.Number{Num: "1"}},
+			&ast.For{
+				Init: &ast.Decl{Name: i, Value: &ast.Number{Num: "0"}},
+				Cond: &ast.Operation{OpType: ast.OpLt, Left: i, Right: x},
+				Post: &ast.Assign{
+					Left:  i,
+					Right: &ast.Operation{OpType: ast.OpAdd, Left: i, Right: &ast.Number{Num: "1"}},
+				},
+				Body: &ast.Block{Name: "for"},
+			},
+			&ast.Decl{Name: &ast.Ident{Name: "p"}, Value: &ast.Ident{Name: "print"}},
+		},
+	}
+
+	if !reflect.DeepEqual(a, expected) {
+		t.Error("unexpected AST")
+	}
+}
+
+func TestFor(t *testing.T) {
+	src := "for i = 0; i; i = i - 1 {\n\tbreak\n}\nfor x {\n\tcontinue\n}\n"
+	lex := lexer.New(strings.NewReader(src))
+	par := New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearSpans(a)
+
+	expected := &ast.Block{
+		Name: "main",
+		Stmts: []ast.Node{
+			&ast.For{
+				Init: &ast.Assign{
+					Left:  &ast.Ident{Name: "i"},
+					Right: &ast.Number{Num: "0"},
+				},
+				Cond: &ast.Ident{Name: "i"},
+				Post: &ast.Assign{
+					Left: &ast.Ident{Name: "i"},
+					Right: &ast.Operation{
+						OpType: ast.OpSub,
+						Left:   &ast.Ident{Name: "i"},
+						Right:  &ast.Number{Num: "1"},
+					},
+				},
+				Body: &ast.Block{
+					Name:  "for",
+					Stmts: []ast.Node{&ast.Break{}},
+				},
+			},
+			&ast.For{
+				Cond: &ast.Ident{Name: "x"},
+				Body: &ast.Block{
+					Name:  "for",
+					Stmts: []ast.Node{&ast.Continue{}},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(a, expected) {
+		t.Error("unexpected AST")
+	}
+}
+
+func TestBreakOutsideLoop(t *testing.T) {
+	src := "for {\n\tfunc f() {\n\t\tbreak\n\t}\n}\ncontinue\n"
+	lex := lexer.New(strings.NewReader(src))
+	par := New(lex)
+	_, err := par.CreateAST()
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected two errors, got %v", err)
+	}
+	if errs[0].Msg != "break outside of loop" || errs[1].Msg != "continue outside of loop" {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
+func TestIfElse(t *testing.T) {
+	src := "if a {\n\tprint(1)\n} else if b {\n\tprint(2)\n} else {\n\tprint(3)\n}\n"
+	lex := lexer.New(strings.NewReader(src))
+	par := New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearSpans(a)
+
+	printCall := func(num string) *ast.Block {
+		return &ast.Block{
+			Stmts: []ast.Node{
+				&ast.FuncCall{
+					Func: &ast.Ident{Name: "print"},
+					Args: []ast.Node{&ast.Number{Num: num}},
+				},
+			},
+		}
+	}
+	then, elseIf, els := printCall("1"), printCall("2"), printCall("3")
+	then.Name, elseIf.Name, els.Name = "if", "if", "else"
+
+	expected := &ast.Block{
+		Name: "main",
+		Stmts: []ast.Node{
+			&ast.If{
+				Cond: &ast.Ident{Name: "a"},
+				Then: then,
+				Else: &ast.If{
+					Cond: &ast.Ident{Name: "b"},
+					Then: elseIf,
+					Else: els,
+				},
 			},
 		},
 	}
@@ -53,13 +243,17 @@ func TestMath(t *testing.T) {
 	}
 	lex := lexer.New(f)
 	par := New(lex)
-	a := par.CreateAST()
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearSpans(a)
 
 	expected := &ast.Block{
 		Name: "main",
 		Stmts: []ast.Node{
 			&ast.FuncCall{
-				Name: "print",
+				Func: &ast.Ident{Name: "print"},
 				Args: []ast.Node{
 					&ast.Operation{
 						OpType: ast.OpAdd,
@@ -88,6 +282,56 @@ func TestMath(t *testing.T) {
 	}
 }
 
+func TestPrecedence(t *testing.T) {
+	lex := lexer.New(strings.NewReader("x = !a || b && c == 1 + 2 * -3\n"))
+	par := New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearSpans(a)
+
+	expected := &ast.Block{
+		Name: "main",
+		Stmts: []ast.Node{
+			&ast.Assign{
+				Left: &ast.Ident{Name: "x"},
+				Right: &ast.Operation{
+					OpType: ast.OpOr,
+					Left: &ast.UnaryOperation{
+						OpType:  ast.OpNot,
+						Operand: &ast.Ident{Name: "a"},
+					},
+					Right: &ast.Operation{
+						OpType: ast.OpAnd,
+						Left:   &ast.Ident{Name: "b"},
+						Right: &ast.Operation{
+							OpType: ast.OpEq,
+							Left:   &ast.Ident{Name: "c"},
+							Right: &ast.Operation{
+								OpType: ast.OpAdd,
+								Left:   &ast.Number{Num: "1"},
+								Right: &ast.Operation{
+									OpType: ast.OpMul,
+									Left:   &ast.Number{Num: "2"},
+									Right: &ast.UnaryOperation{
+										OpType:  ast.OpNeg,
+										Operand: &ast.Number{Num: "3"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(a, expected) {
+		t.Error("unexpected AST")
+	}
+}
+
 func TestPrint(t *testing.T) {
 	f, err := os.Open("../testdata/print.tik")
 	if err != nil {
@@ -95,26 +339,30 @@ func TestPrint(t *testing.T) {
 	}
 	lex := lexer.New(f)
 	par := New(lex)
-	a := par.CreateAST()
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearSpans(a)
 
 	expected := &ast.Block{
 		Name: "main",
 		Stmts: []ast.Node{
 			&ast.FuncCall{
-				Name: "print",
+				Func: &ast.Ident{Name: "print"},
 				Args: []ast.Node{
 					&ast.String{Str: "hello"},
 				},
 			},
 			&ast.FuncCall{
-				Name: "print",
+				Func: &ast.Ident{Name: "print"},
 				Args: []ast.Node{
 					&ast.String{Str: "world1"},
 					&ast.String{Str: "world2"},
 				},
 			},
 			&ast.FuncCall{
-				Name: "print",
+				Func: &ast.Ident{Name: "print"},
 				Args: []ast.Node{
 					&ast.String{Str: "world3"},
 					&ast.Operation{
@@ -143,37 +391,42 @@ func TestVariables(t *testing.T) {
 	}
 	lex := lexer.New(f)
 	par := New(lex)
-	a := par.CreateAST()
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearSpans(a)
 
 	expected := &ast.Block{
 		Name: "main",
 		Stmts: []ast.Node{
-			&ast.Assign{
-				Left: &ast.Ident{Name: "vara"},
-				Right: &ast.Operation{
+			&ast.Decl{
+				Name: &ast.Ident{Name: "vara"},
+				Value: &ast.Operation{
 					OpType: ast.OpAdd,
 					Left:   &ast.Number{Num: "1"},
 					Right:  &ast.Number{Num: "2"},
 				},
 			},
-			&ast.Assign{
-				Left: &ast.Ident{Name: "varb"},
-				Right: &ast.Operation{
+			&ast.Decl{
+				Name: &ast.Ident{Name: "varb"},
+				Value: &ast.Operation{
 					OpType: ast.OpMul,
 					Left:   &ast.Number{Num: "2"},
 					Right:  &ast.Number{Num: "4"},
 				},
 			},
-			&ast.Assign{
-				Left: &ast.Ident{Name: "varc"},
-				Right: &ast.Operation{
+			&ast.Decl{
+				Name: &ast.Ident{Name: "varc"},
+				Value: &ast.Operation{
 					OpType: ast.OpAdd,
 					Left:   &ast.Ident{Name: "vara"},
 					Right:  &ast.Ident{Name: "varb"},
 				},
+				Const: true,
 			},
 			&ast.FuncCall{
-				Name: "print",
+				Func: &ast.Ident{Name: "print"},
 				Args: []ast.Node{
 					&ast.Ident{Name: "vara"},
 					&ast.Ident{Name: "varb"},
@@ -187,3 +440,382 @@ func TestVariables(t *testing.T) {
 		t.Error("unexpected AST")
 	}
 }
+
+func TestSpans(t *testing.T) {
+	lex := lexer.NewFile("spans.tik", strings.NewReader("x = 1 + 23\nprint(x)\n"))
+	par := New(lex)
+	root, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := root.(*ast.Block)
+
+	at := func(line, col, offset int) source.Pos {
+		return source.Pos{Filename: "spans.tik", Offset: offset, Line: line, Column: col}
+	}
+
+	assign := a.Stmts[0].(*ast.Assign)
+	if assign.Pos() != at(1, 1, 0) || assign.End() != at(1, 11, 10) {
+		t.Errorf("unexpected assign span %v-%v", assign.Pos(), assign.End())
+	}
+	op := assign.Right.(*ast.Operation)
+	if op.Pos() != at(1, 5, 4) || op.End() != at(1, 11, 10) {
+		t.Errorf("unexpected operation span %v-%v", op.Pos(), op.End())
+	}
+	call := a.Stmts[1].(*ast.FuncCall)
+	if call.Pos() != at(2, 1, 11) || call.End() != at(2, 9, 19) {
+		t.Errorf("unexpected call span %v-%v", call.Pos(), call.End())
+	}
+	if a.Pos() != assign.Pos() || a.End() != call.End() {
+		t.Errorf("unexpected block span %v-%v", a.Pos(), a.End())
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	src := "x = (1 + 2\nprint(x\nfunc foo(a b) {\n\tprint(a)\n}\ny = 1 +\nprint(\"ok\")\n}\n"
+	lex := lexer.NewFile("errors.tik", strings.NewReader(src))
+	par := New(lex)
+	a, err := par.CreateAST()
+
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("expected ErrorList, got %v", err)
+	}
+
+	expected := []struct {
+		line, col int
+		msg       string
+	}{
+		{1, 5, "unbalanced parenthesis"},
+		{2, 8, "expected comma or paren-right, got newline"},
+		{3, 12, `expected comma or paren-right, got identifier "b"`},
+		{6, 7, "missing operand for operator +"},
+		{8, 1, "unexpected brace-right"},
+	}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d:\n%v", len(expected), len(errs), errs)
+	}
+	for i, exp := range expected {
+		e := errs[i]
+		if e.Pos.Line != exp.line || e.Pos.Column != exp.col || e.Msg != exp.msg {
+			t.Errorf("error %d: expected %d:%d %q, got %v", i, exp.line, exp.col, exp.msg, e)
+		}
+	}
+
+	if got := errs[2].Expected; !reflect.DeepEqual(got, []lexer.TokenType{lexer.TypeComma, lexer.TypeParenR}) {
+		t.Errorf("unexpected expected token types %v", got)
+	}
+	if errs[2].Actual != lexer.TypeIdent {
+		t.Errorf("unexpected actual token type %v", errs[2].Actual)
+	}
+
+	// the valid statement after the errors must still be parsed
+	stmts := a.(*ast.Block).Stmts
+	if len(stmts) != 1 || stmts[0].(*ast.FuncCall).Func.(*ast.Ident).Name != "print" {
+		t.Errorf("unexpected statements %v", stmts)
+	}
+}
+
+func TestUnexpectedEOF(t *testing.T) {
+	lex := lexer.New(strings.NewReader("func foo() {\n\tprint(1)\n"))
+	par := New(lex)
+	_, err := par.CreateAST()
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("expected one error, got %v", err)
+	}
+	if errs[0].Actual != lexer.TypeEOF {
+		t.Errorf("expected end-of-file error, got %v", errs[0])
+	}
+}
+
+func TestLists(t *testing.T) {
+	src := "xs = [1, [2],\n\t3,\n]\nxs[0] = -xs[1][0]\nprint(xs[1:], xs[:-1], xs[:])\n"
+	lex := lexer.New(strings.NewReader(src))
+	par := New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearSpans(a)
+
+	xs := &ast.Ident{Name: "xs"}
+	expected := &ast.Block{
+		Name: "main",
+		Stmts: []ast.Node{
+			&ast.Assign{
+				Left: xs,
+				Right: &ast.List{
+					Elems: []ast.Node{
+						&ast.Number{Num: "1"},
+						&ast.List{Elems: []ast.Node{&ast.Number{Num: "2"}}},
+						&ast.Number{Num: "3"},
+					},
+				},
+			},
+			&ast.Assign{
+				Left: &ast.Index{Left: xs, Index: &ast.Number{Num: "0"}},
+				Right: &ast.UnaryOperation{
+					OpType: ast.OpNeg,
+					Operand: &ast.Index{
+						Left:  &ast.Index{Left: xs, Index: &ast.Number{Num: "1"}},
+						Index: &ast.Number{Num: "0"},
+					},
+				},
+			},
+			&ast.FuncCall{
+				Func: &ast.Ident{Name: "print"},
+				Args: []ast.Node{
+					&ast.Slice{Left: xs, Low: &ast.Number{Num: "1"}},
+					&ast.Slice{Left: xs, High: &ast.UnaryOperation{OpType: ast.OpNeg, Operand: &ast.Number{Num: "1"}}},
+					&ast.Slice{Left: xs},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(a, expected) {
+		t.Error("unexpected AST")
+	}
+}
+
+func TestListErrors(t *testing.T) {
+	src := "xs = [1 2]\nxs[] = 1\nf() = 2\nm = {\"a\" 1}\nif {\n}\n"
+	lex := lexer.New(strings.NewReader(src))
+	par := New(lex)
+	_, err := par.CreateAST()
+
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("expected ErrorList, got %v", err)
+	}
+
+	expected := []string{
+		`1:9: expected comma or bracket-right, got number "2"`,
+		"2:4: expected index, got bracket-right",
+		"3:5: cannot assign to (funccall=f [])",
+		`4:10: expected colon, got number "1"`,
+		"5:4: expected condition after if, got brace-left",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d:\n%v", len(expected), len(errs), errs)
+	}
+	for i, exp := range expected {
+		if errs[i].Error() != exp {
+			t.Errorf("error %d: expected %q, got %q", i, exp, errs[i].Error())
+		}
+	}
+}
+
+func TestMaps(t *testing.T) {
+	src := "m = {\"a\": 1,\n\t2: {},\n}\nif m {\n\tm[\"a\"] = 2\n}\nfor k, v in m {\n}\nfor x in [] {\n}\n"
+	lex := lexer.New(strings.NewReader(src))
+	par := New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearSpans(a)
+
+	m := &ast.Ident{Name: "m"}
+	expected := &ast.Block{
+		Name: "main",
+		Stmts: []ast.Node{
+			&ast.Assign{
+				Left: m,
+				Right: &ast.Map{
+					Keys:   []ast.Node{&ast.String{Str: "a"}, &ast.Number{Num: "2"}},
+					Values: []ast.Node{&ast.Number{Num: "1"}, &ast.Map{}},
+				},
+			},
+			&ast.If{
+				Cond: m,
+				Then: &ast.Block{
+					Name: "if",
+					Stmts: []ast.Node{
+						&ast.Assign{
+							Left:  &ast.Index{Left: m, Index: &ast.String{Str: "a"}},
+							Right: &ast.Number{Num: "2"},
+						},
+					},
+				},
+			},
+			&ast.ForIn{
+				Vars: []*ast.Ident{{Name: "k"}, {Name: "v"}},
+				Iter: m,
+				Body: &ast.Block{Name: "for"},
+			},
+			&ast.ForIn{
+				Vars: []*ast.Ident{{Name: "x"}},
+				Iter: &ast.List{},
+				Body: &ast.Block{Name: "for"},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(a, expected) {
+		t.Error("unexpected AST")
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	lex := lexer.New(strings.NewReader(`x = "a ${b}${c + 1}"` + "\n" + `y = "${}"`))
+	par := New(lex)
+	a, err := par.CreateAST()
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("expected one error, got %v", err)
+	}
+	if errs[0].Msg != "expected expression, got string-end" {
+		t.Errorf("unexpected error %v", errs[0])
+	}
+
+	clearSpans(a)
+
+	expected := &ast.Block{
+		Name: "main",
+		Stmts: []ast.Node{
+			&ast.Assign{
+				Left: &ast.Ident{Name: "x"},
+				Right: &ast.Interpolation{
+					Parts: []ast.Node{
+						&ast.String{Str: "a "},
+						&ast.Ident{Name: "b"},
+						&ast.Operation{
+							OpType: ast.OpAdd,
+							Left:   &ast.Ident{Name: "c"},
+							Right:  &ast.Number{Num: "1"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(a, expected) {
+		t.Error("unexpected AST")
+	}
+}
+
+func TestComments(t *testing.T) {
+	src := "// header\nx = 1 /* inline */ + 2 // trailing\n/* multi\nline */ print(x)\n"
+
+	for _, preserve := range []bool{false, true} {
+		lex := lexer.New(strings.NewReader(src))
+		lex.PreserveComments(preserve)
+		par := New(lex)
+		a, err := par.CreateAST()
+		if err != nil {
+			t.Fatalf("preserve=%v: %v", preserve, err)
+		}
+
+		clearSpans(a)
+
+		expected := &ast.Block{
+			Name: "main",
+			Stmts: []ast.Node{
+				&ast.Assign{
+					Left: &ast.Ident{Name: "x"},
+					Right: &ast.Operation{
+						OpType: ast.OpAdd,
+						Left:   &ast.Number{Num: "1"},
+						Right:  &ast.Number{Num: "2"},
+					},
+				},
+				&ast.FuncCall{
+					Func: &ast.Ident{Name: "print"},
+					Args: []ast.Node{&ast.Ident{Name: "x"}},
+				},
+			},
+		}
+
+		if !reflect.DeepEqual(a, expected) {
+			t.Errorf("preserve=%v: unexpected AST", preserve)
+		}
+	}
+}
+
+func TestInvalidRune(t *testing.T) {
+	lex := lexer.New(strings.NewReader("x = 1 $ 2\nprint(x)\n"))
+	par := New(lex)
+	_, err := par.CreateAST()
+
+	errs, ok := err.(ErrorList)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("expected one error, got %v", err)
+	}
+	if errs[0].Pos.Column != 7 || errs[0].Actual != lexer.TypeInvalid {
+		t.Errorf("unexpected error %v", errs[0])
+	}
+
+	// a lone '&' is read ahead like the operator && before it's rejected
+	for _, src := range []string{"print(1 & 2)\n", "print(1 | 2)\n", "print(1 &"} {
+		_, err = New(lexer.New(strings.NewReader(src))).CreateAST()
+		errs, ok = err.(ErrorList)
+		if !ok || len(errs) == 0 || errs[0].Pos.Column != 9 || errs[0].Actual != lexer.TypeInvalid {
+			t.Errorf("%q: expected invalid rune at column 9, got %v", src, err)
+		}
+	}
+}
+
+func TestEscapeErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		col int
+		msg string
+	}{
+		{"print(\"\\q\")\nprint(1)\n", 8, `unknown escape sequence "\\q"`},
+		{"print(\"\\u{}\")\nprint(1)\n", 8, `invalid unicode escape, expected \u{hex}`},
+		{"print(\"\\u{110000} \\\" \\q\")\nprint(1)\n", 8, "invalid unicode code point 110000"},
+		{"print(\"\\q\nprint(1)\n", 8, `unknown escape sequence "\\q"`},
+	}
+	for _, test := range tests {
+		par := New(lexer.New(strings.NewReader(test.src)))
+		a, err := par.CreateAST()
+
+		errs, ok := err.(ErrorList)
+		if !ok || len(errs) != 1 {
+			t.Errorf("%q: expected one error, got %v", test.src, err)
+			continue
+		}
+		if errs[0].Pos.Line != 1 || errs[0].Pos.Column != test.col || errs[0].Msg != test.msg {
+			t.Errorf("%q: unexpected error %v", test.src, errs[0])
+		}
+		// the statement after the literal is still parsed
+		if stmts := a.(*ast.Block).Stmts; len(stmts) != 1 {
+			t.Errorf("%q: unexpected statements %v", test.src, stmts)
+		}
+	}
+}
+
+// clearSpans removes all source positions from the AST,
+// so that tests can compare the structure only.
+func clearSpans(n ast.Node) {
+	clearValue(reflect.ValueOf(n))
+}
+
+var spanType = reflect.TypeOf(ast.Span{})
+
+func clearValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			clearValue(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			clearValue(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == spanType {
+			v.Set(reflect.Zero(spanType))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			clearValue(v.Field(i))
+		}
+	}
+}
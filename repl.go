@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pseidemann/tik/interpreter"
+	"github.com/pseidemann/tik/lexer"
+	"github.com/pseidemann/tik/parser"
+)
+
+const (
+	promptNew  = ">>> "
+	promptMore = "... "
+)
+
+// repl runs an interactive session until the end of stdin.
+// All inputs are executed by the same interpreter, so definitions remain visible.
+// Inputs which end in the middle of a statement, e.g. inside a block, are continued
+// on the next line. The values of expressions are echoed.
+func repl(stdin io.Reader, stdout, stderr io.Writer) int {
+	rd := bufio.NewReader(stdin)
+	// the interpreter shares the reader, so programs can read the lines after their input
+	in := interpreter.NewWithOptions(interpreter.Options{Stdin: rd, Stdout: stdout, Stderr: stderr})
+	var src strings.Builder
+
+	for {
+		if src.Len() == 0 {
+			fmt.Fprint(stdout, promptNew)
+		} else {
+			fmt.Fprint(stdout, promptMore)
+		}
+		line, err := rd.ReadString('\n')
+		eof := err != nil
+		if eof {
+			// finish the prompt line
+			fmt.Fprintln(stdout)
+		}
+		src.WriteString(line)
+
+		if strings.TrimSpace(src.String()) == "" {
+			src.Reset()
+			if eof {
+				return exitOK
+			}
+			continue
+		}
+
+		par := parser.New(lexer.NewFile("<stdin>", strings.NewReader(src.String())))
+		a, err := par.CreateAST()
+		if err != nil && !eof && isIncomplete(err) {
+			continue
+		}
+		src.Reset()
+
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+		} else {
+			result, err := in.Eval(a)
+			if err != nil {
+				printRuntimeError(stderr, err)
+			} else if result != "" {
+				fmt.Fprintln(stdout, result)
+			}
+		}
+
+		if eof {
+			return exitOK
+		}
+	}
+}
+
+// isIncomplete reports whether the syntax error is caused by input which ended too early.
+func isIncomplete(err error) bool {
+	var errs parser.ErrorList
+	if !errors.As(err, &errs) {
+		return false
+	}
+	for _, e := range errs {
+		if e.Actual == lexer.TypeEOF {
+			return true
+		}
+	}
+	return false
+}
+
+func printRuntimeError(w io.Writer, err error) {
+	if rtErr, ok := err.(*interpreter.RuntimeError); ok {
+		fmt.Fprintln(w, rtErr.Traceback())
+		return
+	}
+	fmt.Fprintln(w, err)
+}
+
+// isTerminal reports whether r is an interactive terminal.
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,30 @@
+// Package source describes locations in tik source code.
+package source
+
+import "fmt"
+
+// Pos is a position in a source file.
+type Pos struct {
+	Filename string `json:"filename,omitempty"`
+	Offset   int    `json:"offset"` // byte offset, starting at 0
+	Line     int    `json:"line"`   // line number, starting at 1
+	Column   int    `json:"column"` // column number in runes, starting at 1
+}
+
+// IsValid reports whether the position is known.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Pos) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
@@ -1,9 +1,32 @@
 // Tik is an interpreted programming language.
+//
+// Usage:
+//
+//	tik [flags] file.tik [args...]   execute a file
+//	tik [flags] - [args...]          execute the program read from stdin
+//	tik [flags] -e code [args...]    execute the given code
+//
+// Without a file, the program is read from stdin. If stdin is a terminal,
+// an interactive session is started instead.
+// The args are available to the program with the builtins argc() and arg(i).
+// The program can read stdin with the builtins input() and readline(),
+// unless the program itself is read from stdin.
+//
+// Instead of executing the program, the flag --tokens prints its tokens and
+// the flag --ast prints its AST. With --format=json, they are printed as JSON.
+// The printed tokens include the comments.
+//
+// The exit code is 0 on success, 1 for runtime errors, 2 for syntax errors
+// and 3 for invalid usage or unreadable files.
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/pseidemann/tik/inspect"
 	"github.com/pseidemann/tik/interpreter"
@@ -11,33 +34,159 @@ import (
 	"github.com/pseidemann/tik/parser"
 )
 
+// Exit codes.
+const (
+	exitOK = iota
+	exitRuntimeError
+	exitSyntaxError
+	exitUsage
+)
+
 func main() {
-	testFile("testdata/func_args.tik")
-	testFile("testdata/func_return.tik")
-	testFile("testdata/func_scope.tik")
-	testFile("testdata/func_simple.tik")
-	testFile("testdata/math.tik")
-	testFile("testdata/print.tik")
-	testFile("testdata/variables.tik")
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
 
-func testFile(filename string) {
-	fmt.Println("### test file", filename)
-	f, err := os.Open(filename)
-	if err != nil {
-		fmt.Println("ERROR: failed to open file:", err)
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("tik", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	code := flags.String("e", "", "execute the given code instead of a file")
+	tokens := flags.Bool("tokens", false, "print the tokens instead of executing")
+	printAST := flags.Bool("ast", false, "print the AST instead of executing")
+	format := flags.String("format", "text", "output `format` of --tokens and --ast: text or json")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: tik [flags] [file.tik | - | -e code] [args...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	args = flags.Args()
+	if *tokens && *printAST {
+		fmt.Fprintln(stderr, "tik: --tokens and --ast can't be combined")
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "tik: unknown format %q\n", *format)
+		return exitUsage
+	}
+	asJSON := *format == "json"
+
+	if len(args) == 0 && !isFlagSet(flags, "e") && !*tokens && !*printAST && isTerminal(stdin) {
+		return repl(stdin, stdout, stderr)
+	}
+
+	var filename string
+	var src io.Reader
+	switch {
+	case isFlagSet(flags, "e"):
+		filename = "-e"
+		src = strings.NewReader(*code)
+	case len(args) == 0 || args[0] == "-":
+		filename = "<stdin>"
+		src = stdin
+		if len(args) > 0 {
+			args = args[1:]
+		}
+	default:
+		filename = args[0]
+		args = args[1:]
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintln(stderr, "tik:", err)
+			return exitUsage
+		}
+		defer f.Close()
+		src = f
+	}
+
+	switch {
+	case *tokens:
+		return dumpTokens(filename, src, asJSON, stdout, stderr)
+	case *printAST:
+		return dumpAST(filename, src, asJSON, stdout, stderr)
+	}
+	return execute(filename, src, stdin, args, stdout, stderr)
+}
+
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func dumpTokens(filename string, src io.Reader, asJSON bool, stdout, stderr io.Writer) int {
+	lex := lexer.NewFile(filename, src)
+	lex.PreserveComments(true)
+	code := exitOK
+	toks := []*lexer.Token{}
+	for {
+		tok, err := lex.NextToken()
+		if err == lexer.ErrEOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = exitSyntaxError
+			if _, ok := err.(*lexer.Error); ok {
+				continue
+			}
+			break
+		}
+		if !asJSON {
+			fmt.Fprintf(stdout, "%v\t%v\n", tok.Pos, tok)
+		}
+		toks = append(toks, tok)
+	}
+	if asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(toks); err != nil {
+			fmt.Fprintln(stderr, "tik:", err)
+			return exitUsage
+		}
 	}
+	return code
+}
 
-	lex := lexer.New(f)
+func dumpAST(filename string, src io.Reader, asJSON bool, stdout, stderr io.Writer) int {
+	lex := lexer.NewFile(filename, src)
 	par := parser.New(lex)
-	a := par.CreateAST()
+	a, err := par.CreateAST()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitSyntaxError
+	}
+	if asJSON {
+		if err := inspect.WriteJSON(stdout, a); err != nil {
+			fmt.Fprintln(stderr, "tik:", err)
+			return exitUsage
+		}
+		return exitOK
+	}
+	inspect.FprintAST(stdout, a)
+	return exitOK
+}
 
-	fmt.Println("--- print ast")
-	inspect.PrintAST(a)
+func execute(filename string, src, stdin io.Reader, args []string, stdout, stderr io.Writer) int {
+	lex := lexer.NewFile(filename, src)
+	par := parser.New(lex)
+	a, err := par.CreateAST()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitSyntaxError
+	}
 
-	fmt.Println("--- execute ast")
-	in := interpreter.New(os.Stdout)
-	in.Execute(a)
+	in := interpreter.NewWithOptions(interpreter.Options{Stdin: stdin, Stdout: stdout, Stderr: stderr})
+	in.SetArgs(args)
+	err = in.Execute(a)
+	if err != nil {
+		printRuntimeError(stderr, err)
+		return exitRuntimeError
+	}
 
-	fmt.Println("--- done")
+	return exitOK
 }
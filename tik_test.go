@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"-e", "print(1+2)"}, "", exitOK, "3\n", ""},
+		{[]string{"-e", "print(argc(), arg(0), arg(1))", "a", "b"}, "", exitOK, "2 a b\n", ""},
+		{[]string{"testdata/math.tik"}, "", exitOK, "70\n", ""},
+		{[]string{"-", "x"}, "print(arg(0))\n", exitOK, "x\n", ""},
+		{nil, "print(\"stdin\")\n", exitOK, "stdin\n", ""},
+		{[]string{"-e", "print(1 +)"}, "", exitSyntaxError, "", "-e:1:9: missing operand for operator +\n"},
+		{[]string{"-e", "print(x)"}, "", exitRuntimeError, "", "-e:1:7: undefined variable \"x\"\n\tat main (-e:1:7)\n"},
+		{[]string{"-e", "let name = input(\"name? \")\nprint(\"hi ${name}\", readline() == \"rest\\n\")\neprint(\"done\")"}, "bob\nrest\n", exitOK, "name? hi bob true\n", "done\n"},
+		{[]string{"testdata/missing.tik"}, "", exitUsage, "", "tik: open testdata/missing.tik: no such file or directory\n"},
+		{[]string{"--tokens", "-e", "x = 1"}, "", exitOK, "-e:1:1\t(identifier<0> \"x\")\n-e:1:3\t(assignment<100> \"\")\n-e:1:5\t(number<0> \"1\")\n", ""},
+		{[]string{"--tokens", "-e", "x // note"}, "", exitOK, "-e:1:1\t(identifier<0> \"x\")\n-e:1:3\t(comment<0> \"// note\")\n", ""},
+		{[]string{"--tokens", "-e", "x $"}, "", exitSyntaxError, "-e:1:1\t(identifier<0> \"x\")\n", "-e:1:3: invalid rune found \"$\"\n"},
+		{[]string{"--ast", "-e", "print(1+2)"}, "", exitOK, "|__ (block=main) <1:1-1:11>\n    |__ (funccall=print [(op `+` left:1 right:2)]) <1:1-1:11>\n", ""},
+		{[]string{"--ast", "--tokens", "-e", "1"}, "", exitUsage, "", "tik: --tokens and --ast can't be combined\n"},
+		{[]string{"--ast", "--format=xml", "-e", "1"}, "", exitUsage, "", "tik: unknown format \"xml\"\n"},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+		if code != test.code {
+			t.Errorf("%v: expected exit code %d, got %d", test.args, test.code, code)
+		}
+		if stdout.String() != test.stdout {
+			t.Errorf("%v: unexpected stdout %q", test.args, stdout.String())
+		}
+		if stderr.String() != test.stderr {
+			t.Errorf("%v: unexpected stderr %q", test.args, stderr.String())
+		}
+	}
+}
+
+func TestDumpJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--tokens", "--format=json", "-e", "x = 1"}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	var toks []struct {
+		Type  string
+		Value string
+		Pos   struct{ Line, Column int }
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &toks); err != nil {
+		t.Fatal(err)
+	}
+	if len(toks) != 3 || toks[0].Type != "identifier" || toks[0].Value != "x" || toks[2].Pos.Column != 5 {
+		t.Errorf("unexpected tokens %+v", toks)
+	}
+
+	stdout.Reset()
+	code = run([]string{"--ast", "--format=json", "-e", "x = 1"}, nil, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("unexpected exit code %d: %s", code, stderr.String())
+	}
+	var root struct {
+		Node  string
+		Stmts []struct {
+			Node  string
+			Left  struct{ Name string }
+			Right struct{ Num string }
+			Pos   struct{ Line, Column int }
+		}
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &root); err != nil {
+		t.Fatal(err)
+	}
+	if root.Node != "Block" || len(root.Stmts) != 1 {
+		t.Fatalf("unexpected AST %+v", root)
+	}
+	assign := root.Stmts[0]
+	if assign.Node != "Assign" || assign.Left.Name != "x" || assign.Right.Num != "1" || assign.Pos.Line != 1 {
+		t.Errorf("unexpected assignment %+v", assign)
+	}
+}
+
+func TestREPL(t *testing.T) {
+	input := "let x = 1\nfunc inc(n) {\n\treturn n + 1\n}\ninc(x)\n\"str\"\nprint(y)\nprint(x +)\ninc(x) == 2\nif x {\n\tprint(\"yes\")\n}\n`multi\nline`\nfunc inc(n) {\n\treturn n + 10\n}\nlet x = 5\ninc(x)\n"
+	var stdout, stderr bytes.Buffer
+	code := repl(strings.NewReader(input), &stdout, &stderr)
+	if code != exitOK {
+		t.Errorf("unexpected exit code %d", code)
+	}
+
+	expectedOut := ">>> >>> ... ... >>> 2\n>>> \"str\"\n>>> >>> >>> true\n>>> ... ... yes\n>>> ... \"multi\\nline\"\n>>> ... ... >>> >>> 15\n>>> \n"
+	if stdout.String() != expectedOut {
+		t.Errorf("unexpected stdout %q", stdout.String())
+	}
+	expectedErr := "<stdin>:1:7: undefined variable \"y\"\n\tat main (<stdin>:1:7)\n<stdin>:1:9: missing operand for operator +\n"
+	if stderr.String() != expectedErr {
+		t.Errorf("unexpected stderr %q", stderr.String())
+	}
+}